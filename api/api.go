@@ -0,0 +1,29 @@
+// Package api embeds the OpenAPI specification of the service
+package api
+
+import (
+	_ "embed"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.4.1 -config client/oapi-codegen.yaml api.yaml
+
+//go:embed api.yaml
+var Spec []byte
+
+// Load parses and validates the specification
+func Load() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+
+	doc, err := loader.LoadFromData(Spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse api spec: %w", err)
+	}
+
+	if err = doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("invalid api spec: %w", err)
+	}
+
+	return doc, nil
+}
@@ -1,39 +1,146 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"microblog/internal/config"
+	"microblog/internal/logging"
 	"microblog/internal/repo"
 	"microblog/internal/service"
+	"microblog/internal/tracing"
+	"net/http"
 	"os"
-)
-
-const (
-	modeServer = "SERVER"
-	modeWorker = "WORKER"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	var r repo.Repository
+	cfg, err := config.Load()
+	if err != nil {
+		fatal(err)
+	}
+
+	if err = logging.Setup(cfg.Logging); err != nil {
+		fatal(err)
+	}
+
+	if err = run(cfg); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+func run(cfg config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("Failed to flush traces", slog.Any("error", err))
+		}
+	}()
 
-	mode, ok := os.LookupEnv("APP_MODE")
-	if !ok {
-		mode = modeServer
+	r, err := newRepository(ctx, cfg)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			slog.Error("Failed to close repository", slog.Any("error", err))
+		}
+	}()
+
+	switch cfg.Mode {
+	case config.ModeServer:
+		srv, err := service.NewServer(cfg, r, nil)
+		if err != nil {
+			return err
+		}
 
-	r = repo.NewRedisRepository(repo.NewMongoDatabaseRepository())
+		slog.Info("Start serving HTTP", slog.String("addr", srv.Addr))
+		return serve(ctx, cfg, srv)
+	case config.ModeWorker:
+		return service.StartConsumer(cfg, r)
+	case config.ModeAll:
+		// tasks queued by the server are processed before the repository is closed
+		queue := service.StartLocalQueue(cfg.Queue)
+		defer queue.Close()
 
-	switch mode {
-	case modeServer:
-		srv, err := service.NewServer(r)
+		srv, err := service.NewServer(cfg, r, queue)
 		if err != nil {
-			log.Fatal(err.Error())
+			return err
 		}
 
-		log.Printf("Start serving HTTP at %s", srv.Addr)
-		log.Fatal(srv.ListenAndServe())
-	case modeWorker:
-		log.Fatal(service.StartConsumer(r))
+		slog.Info("Start serving HTTP with in-process worker", slog.String("addr", srv.Addr))
+		return serve(ctx, cfg, srv)
 	default:
-		log.Fatalf("Unexpected mode flag: %s", mode)
+		return fmt.Errorf("unexpected mode flag: %s", cfg.Mode)
+	}
+}
+
+func newRepository(ctx context.Context, cfg config.Config) (repo.Repository, error) {
+	switch cfg.Storage {
+	case config.StorageMemory:
+		slog.Warn("Using in-memory storage, all data is lost on exit")
+		return repo.NewTracingRepository("memory", repo.NewMemoryRepository()), nil
+	case config.StorageSQLite:
+		// the database is local, so it's not cached in redis
+		r, err := repo.NewSQLiteRepository(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return repo.NewTracingRepository("sqlite", r), nil
+	}
+
+	var persistent repo.Repository
+	var err error
+	switch cfg.Storage {
+	case config.StoragePostgres:
+		persistent, err = repo.NewPostgresRepository(ctx, cfg)
+	default:
+		persistent, err = repo.NewMongoDatabaseRepository(ctx, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := repo.NewRedisRepository(ctx, cfg, repo.NewTracingRepository(cfg.Storage, persistent))
+	if err != nil {
+		_ = persistent.Close()
+		return nil, err
+	}
+
+	return repo.NewTracingRepository("redis", r), nil
+}
+
+func serve(ctx context.Context, cfg config.Config, srv *http.Server) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		slog.Info("Shutting down HTTP server...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.WriteTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(shutdownCtx)
+		if err == nil || errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
 	}
 }
@@ -0,0 +1,77 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Breaker stops calls to a dependency after consecutive failures. Once the cooldown has passed,
+// a single trial call is allowed: its success closes the breaker, its failure opens it again
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	open      bool
+	openedAt  time.Time
+	trial     bool
+	// onChange is called with the new state when the breaker opens or closes
+	onChange func(open bool)
+}
+
+func New(threshold int, cooldown time.Duration, onChange func(open bool)) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, onChange: onChange}
+}
+
+// Allow reports if a call may be made, the caller must report its result with Record
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		if b.open {
+			b.open, b.trial = false, false
+			b.notify()
+		}
+		return
+	}
+
+	b.failures++
+	if b.trial || (!b.open && b.failures >= b.threshold) {
+		wasOpen := b.open
+		b.open, b.trial, b.openedAt = true, false, time.Now()
+		if !wasOpen {
+			b.notify()
+		}
+	}
+}
+
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
+
+func (b *Breaker) notify() {
+	if b.onChange != nil {
+		b.onChange(b.open)
+	}
+}
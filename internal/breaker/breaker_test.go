@@ -0,0 +1,48 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	var changes []bool
+	b := New(2, 20*time.Millisecond, func(open bool) { changes = append(changes, open) })
+	failure := errors.New("connection refused")
+
+	b.Record(failure)
+	b.Record(nil)
+	b.Record(failure)
+	if b.Open() || !b.Allow() {
+		t.Fatal("breaker opened without consecutive failures")
+	}
+
+	b.Record(failure)
+	if !b.Open() || b.Allow() {
+		t.Fatal("breaker is not open after consecutive failures")
+	}
+
+	// a single trial is allowed after the cooldown, its failure opens the breaker again
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow() || b.Allow() {
+		t.Fatal("breaker must allow a single trial after the cooldown")
+	}
+	b.Record(failure)
+	if !b.Open() || b.Allow() {
+		t.Fatal("failed trial must open the breaker")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("breaker must allow a trial after the cooldown")
+	}
+	b.Record(nil)
+	if b.Open() || !b.Allow() || !b.Allow() {
+		t.Fatal("successful trial must close the breaker")
+	}
+
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Fatalf("got state changes %v, want [true false]", changes)
+	}
+}
@@ -0,0 +1,61 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisHook fails redis commands fast while the breaker is open. Error replies of redis itself and missing keys
+// prove that redis is available, so only connection errors and timeouts count as failures
+type RedisHook struct {
+	Breaker *Breaker
+}
+
+func (h RedisHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	if !h.Breaker.Allow() {
+		return ctx, ErrOpen
+	}
+	return ctx, nil
+}
+
+func (h RedisHook) AfterProcess(_ context.Context, cmd redis.Cmder) error {
+	h.record(cmd.Err())
+	return nil
+}
+
+func (h RedisHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	if !h.Breaker.Allow() {
+		return ctx, ErrOpen
+	}
+	return ctx, nil
+}
+
+func (h RedisHook) AfterProcessPipeline(_ context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if failed(cmd.Err()) {
+			err = cmd.Err()
+			break
+		}
+	}
+	h.record(err)
+	return nil
+}
+
+func (h RedisHook) record(err error) {
+	// rejected commands were never sent
+	if errors.Is(err, ErrOpen) {
+		return
+	}
+	if failed(err) {
+		h.Breaker.Record(err)
+	} else {
+		h.Breaker.Record(nil)
+	}
+}
+
+func failed(err error) bool {
+	var redisErr redis.Error
+	return err != nil && err != redis.Nil && !errors.As(err, &redisErr)
+}
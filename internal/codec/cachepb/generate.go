@@ -0,0 +1,4 @@
+// Package cachepb holds protobuf messages of cache entries, they are generated by protoc-gen-go v1.31.0
+package cachepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative cache.proto
@@ -0,0 +1,133 @@
+// Package codec serializes redis cache entries
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"microblog/internal/config"
+)
+
+// Codec serializes values of cache entries
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Compression compresses serialized entries
+type Compression interface {
+	Compress(data []byte) []byte
+	Decompress(data []byte) ([]byte, error)
+}
+
+// Entries start with a prefix of two bytes: the version of the codec and of the compression they were written with.
+// Entries of all versions are decoded, so the format can be changed without flushing redis. Versions are control
+// characters which never start JSON, entries without a prefix were written as JSON before it was added
+const (
+	jsonVersion byte = iota + 1
+	messagePackVersion
+	protobufVersion
+)
+
+const (
+	uncompressedVersion byte = iota + 1
+	snappyVersion
+	zstdVersion
+)
+
+const prefixLength = 2
+
+// maxVersion is the last control character reserved for versions
+const maxVersion byte = 0x1f
+
+var ErrUnknownVersion = errors.New("unknown cache entry version")
+
+var codecs = map[byte]Codec{
+	jsonVersion:        JSON{},
+	messagePackVersion: MessagePack{},
+	protobufVersion:    Protobuf{},
+}
+
+var compressions = map[byte]Compression{
+	snappyVersion: Snappy{},
+	zstdVersion:   newZstd(),
+}
+
+// Format writes entries with a codec, page records are compressed as well
+type Format struct {
+	codec       byte
+	compression byte
+}
+
+// NewFormat accepts names of config.CacheCodec* and config.CacheCompression* constants
+func NewFormat(codec, compression string) (Format, error) {
+	var format Format
+
+	switch codec {
+	case config.CacheCodecJSON:
+		format.codec = jsonVersion
+	case config.CacheCodecMessagePack:
+		format.codec = messagePackVersion
+	case config.CacheCodecProtobuf:
+		format.codec = protobufVersion
+	default:
+		return Format{}, fmt.Errorf("unexpected cache codec %q", codec)
+	}
+
+	switch compression {
+	case config.CacheCompressionNone:
+		format.compression = uncompressedVersion
+	case config.CacheCompressionSnappy:
+		format.compression = snappyVersion
+	case config.CacheCompressionZstd:
+		format.compression = zstdVersion
+	default:
+		return Format{}, fmt.Errorf("unexpected cache compression %q", compression)
+	}
+
+	return format, nil
+}
+
+// Marshal encodes v with the prefix, it's compressed only if compress is set
+func (f Format) Marshal(v any, compress bool) ([]byte, error) {
+	data, err := codecs[f.codec].Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	compression := uncompressedVersion
+	if compress && f.compression != uncompressedVersion {
+		compression = f.compression
+		data = compressions[compression].Compress(data)
+	}
+
+	return append([]byte{f.codec, compression}, data...), nil
+}
+
+// Unmarshal decodes an entry of any version into v
+func Unmarshal(data []byte, v any) error {
+	if len(data) == 0 || data[0] > maxVersion {
+		return JSON{}.Unmarshal(data, v)
+	}
+	if len(data) < prefixLength {
+		return ErrUnknownVersion
+	}
+
+	c, ok := codecs[data[0]]
+	if !ok {
+		return ErrUnknownVersion
+	}
+
+	payload := data[prefixLength:]
+	switch compression := data[1]; {
+	case compression == uncompressedVersion:
+	case compressions[compression] != nil:
+		var err error
+		if payload, err = compressions[compression].Decompress(payload); err != nil {
+			return fmt.Errorf("failed to decompress cache entry: %w", err)
+		}
+	default:
+		return ErrUnknownVersion
+	}
+
+	return c.Unmarshal(payload, v)
+}
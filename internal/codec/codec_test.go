@@ -0,0 +1,137 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"microblog/internal/config"
+	"microblog/internal/model"
+	"reflect"
+	"testing"
+)
+
+var post = model.Post{
+	Id:             "65f1c2a3b4d5e6f708192a3b",
+	Text:           "hello",
+	AuthorId:       "aa",
+	CreatedAt:      "2024-03-13T10:00:00Z",
+	LastModifiedAt: "2024-03-13T10:05:00.123Z",
+}
+
+var page = model.FeedPage{
+	Posts:    []model.Post{post, {Id: "65f1c2a3b4d5e6f708192a3c", Text: "world", AuthorId: "bb"}},
+	Cursors:  model.PageCursors{Older: "older", Newer: "newer"},
+	Dangling: []model.PostId{"65f1c2a3b4d5e6f708192a3d"},
+}
+
+var timelinePage = model.TimelinePage{
+	Ids:     []model.PostId{"65f1c2a3b4d5e6f708192a3b", "65f1c2a3b4d5e6f708192a3c"},
+	Cursors: model.PageCursors{Older: "older", Newer: "newer"},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, c := range []string{config.CacheCodecJSON, config.CacheCodecMessagePack, config.CacheCodecProtobuf} {
+		for _, compression := range []string{config.CacheCompressionNone, config.CacheCompressionSnappy, config.CacheCompressionZstd} {
+			t.Run(c+"/"+compression, func(t *testing.T) {
+				format, err := NewFormat(c, compression)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				data, err := format.Marshal(post, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var gotPost model.Post
+				if err = Unmarshal(data, &gotPost); err != nil || gotPost != post {
+					t.Fatalf("got %+v (error %v), want %+v", gotPost, err, post)
+				}
+
+				data, err = format.Marshal(page, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var gotPage model.FeedPage
+				if err = Unmarshal(data, &gotPage); err != nil || !reflect.DeepEqual(gotPage, page) {
+					t.Fatalf("got %+v (error %v), want %+v", gotPage, err, page)
+				}
+
+				data, err = format.Marshal(timelinePage, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var gotTimelinePage model.TimelinePage
+				if err = Unmarshal(data, &gotTimelinePage); err != nil || !reflect.DeepEqual(gotTimelinePage, timelinePage) {
+					t.Fatalf("got %+v (error %v), want %+v", gotTimelinePage, err, timelinePage)
+				}
+			})
+		}
+	}
+}
+
+func TestOnlyPagesAreCompressed(t *testing.T) {
+	format, err := NewFormat(config.CacheCodecJSON, config.CacheCompressionZstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := format.Marshal(post, false)
+	if data[1] != uncompressedVersion {
+		t.Fatalf("post was compressed with version %d", data[1])
+	}
+	data, _ = format.Marshal(page, true)
+	if data[1] != zstdVersion {
+		t.Fatalf("page was compressed with version %d, want %d", data[1], zstdVersion)
+	}
+}
+
+func TestEntryWithoutPrefixIsJSON(t *testing.T) {
+	data, _ := json.Marshal(page)
+
+	var got model.FeedPage
+	if err := Unmarshal(data, &got); err != nil || !reflect.DeepEqual(got, page) {
+		t.Fatalf("got %+v (error %v), want %+v", got, err, page)
+	}
+}
+
+func TestUndecodableEntries(t *testing.T) {
+	format, _ := NewFormat(config.CacheCodecMessagePack, config.CacheCompressionSnappy)
+	compressed, _ := format.Marshal(page, true)
+
+	for name, data := range map[string][]byte{
+		"UnknownCodec":       {protobufVersion + 1, uncompressedVersion, '{', '}'},
+		"UnknownCompression": {jsonVersion, zstdVersion + 1, '{', '}'},
+		"NoPayload":          {jsonVersion},
+		"Corrupted":          compressed[:len(compressed)/2],
+		"Empty":              {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got model.FeedPage
+			if err := Unmarshal(data, &got); err == nil {
+				t.Fatalf("decoded %+v", got)
+			}
+		})
+	}
+
+	t.Run("UnknownVersion", func(t *testing.T) {
+		var got model.Post
+		if err := Unmarshal([]byte{protobufVersion + 1, uncompressedVersion}, &got); !errors.Is(err, ErrUnknownVersion) {
+			t.Fatalf("got error %v, want %v", err, ErrUnknownVersion)
+		}
+	})
+}
+
+func TestProtobufSupportsModelTypes(t *testing.T) {
+	format, _ := NewFormat(config.CacheCodecProtobuf, config.CacheCompressionNone)
+	if _, err := format.Marshal([]model.UserId{"aa"}, false); err == nil {
+		t.Fatal("unsupported value was encoded")
+	}
+}
+
+func TestNewFormat(t *testing.T) {
+	if _, err := NewFormat("xml", config.CacheCompressionNone); err == nil {
+		t.Fatal("unknown codec was accepted")
+	}
+	if _, err := NewFormat(config.CacheCodecJSON, "gzip"); err == nil {
+		t.Fatal("unknown compression was accepted")
+	}
+}
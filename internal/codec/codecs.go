@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type JSON struct{}
+
+func (JSON) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSON) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// MessagePack uses json tags, so both codecs write the same fields
+type MessagePack struct{}
+
+func (MessagePack) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MessagePack) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+type Snappy struct{}
+
+func (Snappy) Compress(data []byte) []byte {
+	return snappy.Encode(nil, data)
+}
+
+func (Snappy) Decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
+
+// Zstd shares an encoder and a decoder, they are safe for concurrent use of EncodeAll and DecodeAll
+type Zstd struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func newZstd() Zstd {
+	encoder, _ := zstd.NewWriter(nil)
+	decoder, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	return Zstd{encoder: encoder, decoder: decoder}
+}
+
+func (z Zstd) Compress(data []byte) []byte {
+	return z.encoder.EncodeAll(data, nil)
+}
+
+func (z Zstd) Decompress(data []byte) ([]byte, error) {
+	return z.decoder.DecodeAll(data, nil)
+}
@@ -0,0 +1,127 @@
+package codec
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"microblog/internal/codec/cachepb"
+	"microblog/internal/model"
+)
+
+// Protobuf encodes model types through messages of cachepb, other values are not supported
+type Protobuf struct{}
+
+func (Protobuf) Marshal(v any) ([]byte, error) {
+	var message proto.Message
+	switch value := v.(type) {
+	case model.Post:
+		message = postMessage(value)
+	case *model.Post:
+		message = postMessage(*value)
+	case model.FeedPage:
+		message = feedPageMessage(value)
+	case *model.FeedPage:
+		message = feedPageMessage(*value)
+	case model.TimelinePage:
+		message = timelinePageMessage(value)
+	case *model.TimelinePage:
+		message = timelinePageMessage(*value)
+	default:
+		return nil, fmt.Errorf("protobuf codec does not support %T", v)
+	}
+	return proto.Marshal(message)
+}
+
+func (Protobuf) Unmarshal(data []byte, v any) error {
+	switch value := v.(type) {
+	case *model.Post:
+		var message cachepb.Post
+		if err := proto.Unmarshal(data, &message); err != nil {
+			return err
+		}
+		*value = postOf(&message)
+	case *model.FeedPage:
+		var message cachepb.FeedPage
+		if err := proto.Unmarshal(data, &message); err != nil {
+			return err
+		}
+		*value = feedPageOf(&message)
+	case *model.TimelinePage:
+		var message cachepb.TimelinePage
+		if err := proto.Unmarshal(data, &message); err != nil {
+			return err
+		}
+		*value = timelinePageOf(&message)
+	default:
+		return fmt.Errorf("protobuf codec does not support %T", v)
+	}
+	return nil
+}
+
+func postMessage(post model.Post) *cachepb.Post {
+	return &cachepb.Post{
+		Id:             string(post.Id),
+		Text:           post.Text,
+		AuthorId:       string(post.AuthorId),
+		CreatedAt:      string(post.CreatedAt),
+		LastModifiedAt: string(post.LastModifiedAt),
+	}
+}
+
+func postOf(message *cachepb.Post) model.Post {
+	return model.Post{
+		Id:             model.PostId(message.GetId()),
+		Text:           message.GetText(),
+		AuthorId:       model.UserId(message.GetAuthorId()),
+		CreatedAt:      model.ISOTimestamp(message.GetCreatedAt()),
+		LastModifiedAt: model.ISOTimestamp(message.GetLastModifiedAt()),
+	}
+}
+
+func feedPageMessage(page model.FeedPage) *cachepb.FeedPage {
+	message := &cachepb.FeedPage{
+		Posts:   make([]*cachepb.Post, len(page.Posts)),
+		Cursors: cursorsMessage(page.Cursors),
+	}
+	for i, post := range page.Posts {
+		message.Posts[i] = postMessage(post)
+	}
+	for _, id := range page.Dangling {
+		message.Dangling = append(message.Dangling, string(id))
+	}
+	return message
+}
+
+func feedPageOf(message *cachepb.FeedPage) model.FeedPage {
+	page := model.FeedPage{Cursors: cursorsOf(message.GetCursors())}
+	for _, post := range message.GetPosts() {
+		page.Posts = append(page.Posts, postOf(post))
+	}
+	for _, id := range message.GetDangling() {
+		page.Dangling = append(page.Dangling, model.PostId(id))
+	}
+	return page
+}
+
+func cursorsMessage(cursors model.PageCursors) *cachepb.PageCursors {
+	return &cachepb.PageCursors{Older: string(cursors.Older), Newer: string(cursors.Newer)}
+}
+
+func cursorsOf(message *cachepb.PageCursors) model.PageCursors {
+	return model.PageCursors{Older: model.PageToken(message.GetOlder()), Newer: model.PageToken(message.GetNewer())}
+}
+
+func timelinePageMessage(page model.TimelinePage) *cachepb.TimelinePage {
+	message := &cachepb.TimelinePage{Cursors: cursorsMessage(page.Cursors)}
+	for _, id := range page.Ids {
+		message.Ids = append(message.Ids, string(id))
+	}
+	return message
+}
+
+func timelinePageOf(message *cachepb.TimelinePage) model.TimelinePage {
+	page := model.TimelinePage{Cursors: cursorsOf(message.GetCursors())}
+	for _, id := range message.GetIds() {
+		page.Ids = append(page.Ids, model.PostId(id))
+	}
+	return page
+}
@@ -0,0 +1,548 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ModeServer = "SERVER"
+	ModeWorker = "WORKER"
+	// ModeAll runs the server and the worker in one process, tasks are passed through an in-process queue
+	ModeAll = "ALL"
+)
+
+const (
+	StorageMongo    = "mongo"
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+)
+
+const minTokenSecretLength = 16
+
+const (
+	TracingExporterNone = "none"
+	TracingExporterOTLP = "otlp"
+	TracingExporterFile = "file"
+)
+
+const (
+	CacheCodecJSON        = "json"
+	CacheCodecMessagePack = "msgpack"
+	CacheCodecProtobuf    = "protobuf"
+)
+
+const (
+	CacheCompressionNone   = "none"
+	CacheCompressionSnappy = "snappy"
+	CacheCompressionZstd   = "zstd"
+)
+
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+type Config struct {
+	Mode        string            `yaml:"mode" toml:"mode"`
+	Storage     string            `yaml:"storage" toml:"storage"`
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Mongo       MongoConfig       `yaml:"mongo" toml:"mongo"`
+	Postgres    PostgresConfig    `yaml:"postgres" toml:"postgres"`
+	SQLite      SQLiteConfig      `yaml:"sqlite" toml:"sqlite"`
+	Redis       RedisConfig       `yaml:"redis" toml:"redis"`
+	Cache       CacheConfig       `yaml:"cache" toml:"cache"`
+	Pagination  PaginationConfig  `yaml:"pagination" toml:"pagination"`
+	Queue       QueueConfig       `yaml:"queue" toml:"queue"`
+	Retry       RetryConfig       `yaml:"retry" toml:"retry"`
+	Health      HealthConfig      `yaml:"health" toml:"health"`
+	Tracing     TracingConfig     `yaml:"tracing" toml:"tracing"`
+	Logging     LoggingConfig     `yaml:"logging" toml:"logging"`
+	Validation  ValidationConfig  `yaml:"validation" toml:"validation"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency"`
+	RateLimit   RateLimitConfig   `yaml:"rateLimit" toml:"rateLimit"`
+	OpenAPI     OpenAPIConfig     `yaml:"openAPI" toml:"openAPI"`
+}
+
+type ServerConfig struct {
+	Port         int           `yaml:"port" toml:"port"`
+	ReadTimeout  time.Duration `yaml:"readTimeout" toml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout" toml:"writeTimeout"`
+}
+
+type MongoConfig struct {
+	URL            string        `yaml:"url" toml:"url"`
+	DBName         string        `yaml:"dbName" toml:"dbName"`
+	ConnectTimeout time.Duration `yaml:"connectTimeout" toml:"connectTimeout"`
+	IndexTimeout   time.Duration `yaml:"indexTimeout" toml:"indexTimeout"`
+}
+
+type PostgresConfig struct {
+	URL            string        `yaml:"url" toml:"url"`
+	ConnectTimeout time.Duration `yaml:"connectTimeout" toml:"connectTimeout"`
+	// MigrationTimeout limits applying of schema migrations at startup
+	MigrationTimeout time.Duration `yaml:"migrationTimeout" toml:"migrationTimeout"`
+}
+
+type SQLiteConfig struct {
+	Path string `yaml:"path" toml:"path"`
+	// BusyTimeout is how long a write waits for another one to release the database
+	BusyTimeout time.Duration `yaml:"busyTimeout" toml:"busyTimeout"`
+}
+
+type RedisConfig struct {
+	// Addr is always stored as bare "host:port", both for the cache client and for the task broker
+	Addr           string        `yaml:"addr" toml:"addr"`
+	ConnectTimeout time.Duration `yaml:"connectTimeout" toml:"connectTimeout"`
+	// OperationTimeout bounds reads and writes of cache commands, slow commands count as failures of the breaker
+	OperationTimeout time.Duration `yaml:"operationTimeout" toml:"operationTimeout"`
+	// BreakerThreshold is the number of consecutive failures after which the cache is bypassed for BreakerCooldown
+	BreakerThreshold int           `yaml:"breakerThreshold" toml:"breakerThreshold"`
+	BreakerCooldown  time.Duration `yaml:"breakerCooldown" toml:"breakerCooldown"`
+	// InvalidationQueueSize limits the number of failed invalidations kept for replay
+	InvalidationQueueSize int `yaml:"invalidationQueueSize" toml:"invalidationQueueSize"`
+}
+
+// RetryConfig describes exponential backoff used while connecting to dependencies at startup
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"maxAttempts" toml:"maxAttempts"`
+	InitialBackoff time.Duration `yaml:"initialBackoff" toml:"initialBackoff"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff" toml:"maxBackoff"`
+}
+
+type HealthConfig struct {
+	CheckTimeout time.Duration `yaml:"checkTimeout" toml:"checkTimeout"`
+	// WorkerPort is a port of the side HTTP listener with maintenance endpoints in WORKER mode
+	WorkerPort int `yaml:"workerPort" toml:"workerPort"`
+}
+
+type TracingConfig struct {
+	// Exporter is one of "none", "otlp" (OTLP over HTTP) or "file" (JSON lines, mostly for tests)
+	Exporter     string  `yaml:"exporter" toml:"exporter"`
+	OTLPEndpoint string  `yaml:"otlpEndpoint" toml:"otlpEndpoint"`
+	FilePath     string  `yaml:"filePath" toml:"filePath"`
+	ServiceName  string  `yaml:"serviceName" toml:"serviceName"`
+	SampleRatio  float64 `yaml:"sampleRatio" toml:"sampleRatio"`
+}
+
+type LoggingConfig struct {
+	// Level is one of slog levels: "debug", "info", "warn" or "error"
+	Level  string `yaml:"level" toml:"level"`
+	Format string `yaml:"format" toml:"format"`
+}
+
+type CacheConfig struct {
+	PostTTL          time.Duration `yaml:"postTTL" toml:"postTTL"`
+	PageTTL          time.Duration `yaml:"pageTTL" toml:"pageTTL"`
+	SubscriptionsTTL time.Duration `yaml:"subscriptionsTTL" toml:"subscriptionsTTL"`
+	// HydratedFeedTTL enables caching of feed pages with posts, edits may be stale on them for that long
+	HydratedFeedTTL time.Duration `yaml:"hydratedFeedTTL" toml:"hydratedFeedTTL"`
+	// TimelineLength is the number of the newest items of post lists and feeds kept in redis
+	TimelineLength int `yaml:"timelineLength" toml:"timelineLength"`
+	// NegativeTTL is the TTL of cached lookups of posts which don't exist
+	NegativeTTL time.Duration `yaml:"negativeTTL" toml:"negativeTTL"`
+	// TTLJitter is the fraction of a TTL by which it is randomly shortened or extended
+	TTLJitter float64 `yaml:"ttlJitter" toml:"ttlJitter"`
+	// EarlyRefresh is the period before expiration when hits may refresh entries in background, zero disables it
+	EarlyRefresh time.Duration `yaml:"earlyRefresh" toml:"earlyRefresh"`
+	// LoadTimeout limits loads from the persistent repository which are shared by concurrent misses of a key
+	LoadTimeout time.Duration `yaml:"loadTimeout" toml:"loadTimeout"`
+	// ReconcileInterval is the period of comparing cached user lists with the persistent repository in workers,
+	// zero disables it
+	ReconcileInterval time.Duration `yaml:"reconcileInterval" toml:"reconcileInterval"`
+	// Codec is one of CacheCodec* constants, entries written with other codecs are still read
+	Codec string `yaml:"codec" toml:"codec"`
+	// PageCompression is one of CacheCompression* constants, it applies to cached pages only
+	PageCompression string `yaml:"pageCompression" toml:"pageCompression"`
+}
+
+type PaginationConfig struct {
+	DefaultSize int `yaml:"defaultSize" toml:"defaultSize"`
+	MaxSize     int `yaml:"maxSize" toml:"maxSize"`
+	// MaxUnreadCount caps counting of unread feed items
+	MaxUnreadCount int `yaml:"maxUnreadCount" toml:"maxUnreadCount"`
+	// TokenSecret signs page tokens, it must be shared by all servers. It may be empty only with standalone storages,
+	// then a random secret per process is used
+	TokenSecret string `yaml:"tokenSecret" toml:"tokenSecret"`
+}
+
+type ValidationConfig struct {
+	// MaxPostLength is measured in user-perceived characters (grapheme clusters)
+	MaxPostLength int `yaml:"maxPostLength" toml:"maxPostLength"`
+	MaxBodyBytes  int `yaml:"maxBodyBytes" toml:"maxBodyBytes"`
+}
+
+type IdempotencyConfig struct {
+	// Window is how long responses are replayed for repeated Idempotency-Key
+	Window time.Duration `yaml:"window" toml:"window"`
+	// LockTimeout bounds how long a key is held by a request in progress
+	LockTimeout time.Duration `yaml:"lockTimeout" toml:"lockTimeout"`
+}
+
+// RateLimitConfig describes token buckets per user (or client IP for anonymous requests), rates are per second
+type RateLimitConfig struct {
+	Enabled    bool    `yaml:"enabled" toml:"enabled"`
+	WriteRate  float64 `yaml:"writeRate" toml:"writeRate"`
+	WriteBurst int     `yaml:"writeBurst" toml:"writeBurst"`
+	ReadRate   float64 `yaml:"readRate" toml:"readRate"`
+	ReadBurst  int     `yaml:"readBurst" toml:"readBurst"`
+	// TrustProxy enables taking client IP from X-Forwarded-For
+	TrustProxy bool `yaml:"trustProxy" toml:"trustProxy"`
+}
+
+// OpenAPIConfig enables checking of the API against api/api.yaml
+type OpenAPIConfig struct {
+	ValidateRequests bool `yaml:"validateRequests" toml:"validateRequests"`
+	// ValidateResponses buffers every response, it is meant for tests
+	ValidateResponses bool `yaml:"validateResponses" toml:"validateResponses"`
+}
+
+type QueueConfig struct {
+	Name                   string        `yaml:"name" toml:"name"`
+	ConsumerTag            string        `yaml:"consumerTag" toml:"consumerTag"`
+	Concurrency            int           `yaml:"concurrency" toml:"concurrency"`
+	ResultsExpireIn        time.Duration `yaml:"resultsExpireIn" toml:"resultsExpireIn"`
+	MaxIdle                int           `yaml:"maxIdle" toml:"maxIdle"`
+	IdleTimeout            time.Duration `yaml:"idleTimeout" toml:"idleTimeout"`
+	ReadTimeout            time.Duration `yaml:"readTimeout" toml:"readTimeout"`
+	WriteTimeout           time.Duration `yaml:"writeTimeout" toml:"writeTimeout"`
+	ConnectTimeout         time.Duration `yaml:"connectTimeout" toml:"connectTimeout"`
+	NormalTasksPollPeriod  time.Duration `yaml:"normalTasksPollPeriod" toml:"normalTasksPollPeriod"`
+	DelayedTasksPollPeriod time.Duration `yaml:"delayedTasksPollPeriod" toml:"delayedTasksPollPeriod"`
+	DrainPageSize          int           `yaml:"drainPageSize" toml:"drainPageSize"`
+	// LocalSize is the capacity of the in-process queue of ALL mode, producers wait while it's full
+	LocalSize int `yaml:"localSize" toml:"localSize"`
+}
+
+func Default() Config {
+	return Config{
+		Mode:    ModeServer,
+		Storage: StorageMongo,
+		Server: ServerConfig{
+			Port:         8080,
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+		},
+		Mongo: MongoConfig{
+			URL:            "mongodb://localhost:27017",
+			DBName:         "system_design",
+			ConnectTimeout: 5 * time.Second,
+			IndexTimeout:   10 * time.Second,
+		},
+		Postgres: PostgresConfig{
+			URL:              "postgres://localhost:5432/microblog",
+			ConnectTimeout:   5 * time.Second,
+			MigrationTimeout: 30 * time.Second,
+		},
+		SQLite: SQLiteConfig{
+			Path:        "microblog.db",
+			BusyTimeout: 5 * time.Second,
+		},
+		Redis: RedisConfig{
+			Addr:                  "127.0.0.1:6379",
+			ConnectTimeout:        5 * time.Second,
+			OperationTimeout:      500 * time.Millisecond,
+			BreakerThreshold:      5,
+			BreakerCooldown:       5 * time.Second,
+			InvalidationQueueSize: 10000,
+		},
+		Cache: CacheConfig{
+			PostTTL:           time.Hour,
+			PageTTL:           time.Hour,
+			SubscriptionsTTL:  time.Hour,
+			TimelineLength:    800,
+			NegativeTTL:       30 * time.Second,
+			TTLJitter:         0.1,
+			EarlyRefresh:      time.Minute,
+			LoadTimeout:       5 * time.Second,
+			ReconcileInterval: 10 * time.Minute,
+			Codec:             CacheCodecJSON,
+			PageCompression:   CacheCompressionNone,
+		},
+		Pagination: PaginationConfig{
+			DefaultSize:    10,
+			MaxSize:        100,
+			MaxUnreadCount: 1000,
+		},
+		Queue: QueueConfig{
+			Name:                   "machinery_tasks",
+			ConsumerTag:            "machinery_worker",
+			Concurrency:            0,
+			ResultsExpireIn:        time.Hour,
+			MaxIdle:                3,
+			IdleTimeout:            240 * time.Second,
+			ReadTimeout:            15 * time.Second,
+			WriteTimeout:           15 * time.Second,
+			ConnectTimeout:         15 * time.Second,
+			NormalTasksPollPeriod:  time.Second,
+			DelayedTasksPollPeriod: 500 * time.Millisecond,
+			DrainPageSize:          100,
+			LocalSize:              1000,
+		},
+		Retry: RetryConfig{
+			MaxAttempts:    5,
+			InitialBackoff: 500 * time.Millisecond,
+			MaxBackoff:     10 * time.Second,
+		},
+		Health: HealthConfig{
+			CheckTimeout: time.Second,
+			WorkerPort:   8081,
+		},
+		Tracing: TracingConfig{
+			Exporter:     TracingExporterNone,
+			OTLPEndpoint: "localhost:4318",
+			ServiceName:  "microblog",
+			SampleRatio:  1,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: LogFormatJSON,
+		},
+		Validation: ValidationConfig{
+			MaxPostLength: 280,
+			MaxBodyBytes:  16 << 10,
+		},
+		Idempotency: IdempotencyConfig{
+			Window:      24 * time.Hour,
+			LockTimeout: 30 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:    true,
+			WriteRate:  1,
+			WriteBurst: 10,
+			ReadRate:   20,
+			ReadBurst:  100,
+		},
+		OpenAPI: OpenAPIConfig{
+			ValidateRequests: true,
+		},
+	}
+}
+
+// BrokerURL returns the redis address in the form expected by machinery
+func (c RedisConfig) BrokerURL() string {
+	return "redis://" + c.Addr
+}
+
+// Standalone reports if the storage lives in the process itself, then the service doesn't use redis at all:
+// there is no cache, idempotency records and rate limits are kept in memory
+func (c Config) Standalone() bool {
+	return c.Storage == StorageMemory || c.Storage == StorageSQLite
+}
+
+// normalizeRedisAddr accepts both "host:port" and "redis://host:port"
+func normalizeRedisAddr(addr string) (string, error) {
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return "", fmt.Errorf("invalid redis url %q: %w", addr, err)
+		}
+		if u.Scheme != "redis" {
+			return "", fmt.Errorf("unsupported redis url scheme %q", u.Scheme)
+		}
+		addr = u.Host
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return "", fmt.Errorf("invalid redis address %q: %w", addr, err)
+	}
+
+	return addr, nil
+}
+
+func (c *Config) Validate() error {
+	var errs []string
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if c.Mode != ModeServer && c.Mode != ModeWorker && c.Mode != ModeAll {
+		fail("unexpected mode %q", c.Mode)
+	}
+
+	if c.Storage != StorageMongo && c.Storage != StoragePostgres && c.Storage != StorageSQLite && c.Storage != StorageMemory {
+		fail("unexpected storage %q", c.Storage)
+	}
+	// memory storage is not shared between processes, so tasks are processed by the server itself
+	if c.Storage == StorageMemory && c.Mode == ModeWorker {
+		fail("%s storage is supported only in %s and %s modes", StorageMemory, ModeServer, ModeAll)
+	}
+	if c.Storage == StorageSQLite && c.Mode != ModeAll {
+		fail("%s storage is supported only in %s mode", StorageSQLite, ModeAll)
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		fail("server port %d is out of range", c.Server.Port)
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 {
+		fail("server timeouts must be positive")
+	}
+
+	if _, err := url.Parse(c.Mongo.URL); err != nil || !strings.HasPrefix(c.Mongo.URL, "mongodb") {
+		fail("invalid mongo url %q", c.Mongo.URL)
+	}
+	if c.Mongo.DBName == "" {
+		fail("mongo database name is empty")
+	}
+	if c.Mongo.ConnectTimeout <= 0 || c.Mongo.IndexTimeout <= 0 {
+		fail("mongo timeouts must be positive")
+	}
+
+	if _, err := url.Parse(c.Postgres.URL); err != nil || !strings.HasPrefix(c.Postgres.URL, "postgres") {
+		fail("invalid postgres url %q", c.Postgres.URL)
+	}
+	if c.Postgres.ConnectTimeout <= 0 || c.Postgres.MigrationTimeout <= 0 {
+		fail("postgres timeouts must be positive")
+	}
+
+	if c.SQLite.Path == "" {
+		fail("sqlite path is empty")
+	}
+	if c.SQLite.BusyTimeout <= 0 {
+		fail("sqlite busy timeout must be positive")
+	}
+
+	if addr, err := normalizeRedisAddr(c.Redis.Addr); err != nil {
+		fail("%s", err)
+	} else {
+		c.Redis.Addr = addr
+	}
+	if c.Redis.ConnectTimeout <= 0 || c.Redis.OperationTimeout <= 0 {
+		fail("redis timeouts must be positive")
+	}
+	if c.Redis.BreakerThreshold < 1 || c.Redis.BreakerCooldown <= 0 {
+		fail("redis breaker threshold and cooldown must be positive")
+	}
+	if c.Redis.InvalidationQueueSize < 1 {
+		fail("redis invalidation queue size must be positive")
+	}
+
+	if c.Cache.PostTTL <= 0 || c.Cache.PageTTL <= 0 || c.Cache.SubscriptionsTTL <= 0 {
+		fail("cache ttls must be positive")
+	}
+	if c.Cache.HydratedFeedTTL < 0 {
+		fail("hydrated feed ttl must not be negative")
+	}
+	if c.Cache.TimelineLength < 1 {
+		fail("timeline length must be positive")
+	}
+	if c.Cache.NegativeTTL <= 0 {
+		fail("negative cache ttl must be positive")
+	}
+	if c.Cache.TTLJitter < 0 || c.Cache.TTLJitter >= 1 {
+		fail("cache ttl jitter must be in [0, 1)")
+	}
+	if c.Cache.EarlyRefresh < 0 {
+		fail("cache early refresh must not be negative")
+	}
+	if c.Cache.LoadTimeout <= 0 {
+		fail("cache load timeout must be positive")
+	}
+	if c.Cache.ReconcileInterval < 0 {
+		fail("cache reconcile interval must not be negative")
+	}
+	if c.Cache.Codec != CacheCodecJSON && c.Cache.Codec != CacheCodecMessagePack && c.Cache.Codec != CacheCodecProtobuf {
+		fail("unexpected cache codec %q", c.Cache.Codec)
+	}
+	if c.Cache.PageCompression != CacheCompressionNone && c.Cache.PageCompression != CacheCompressionSnappy &&
+		c.Cache.PageCompression != CacheCompressionZstd {
+		fail("unexpected cache page compression %q", c.Cache.PageCompression)
+	}
+
+	if c.Pagination.DefaultSize < 1 || c.Pagination.MaxSize < 1 {
+		fail("page sizes must be positive")
+	} else if c.Pagination.DefaultSize > c.Pagination.MaxSize {
+		fail("default page size %d exceeds max page size %d", c.Pagination.DefaultSize, c.Pagination.MaxSize)
+	}
+	if c.Pagination.MaxUnreadCount < 1 {
+		fail("max unread count must be positive")
+	}
+	// a random secret of every process would break tokens on other replicas and after restarts
+	switch {
+	case c.Pagination.TokenSecret == "" && c.Standalone():
+	case len(c.Pagination.TokenSecret) < minTokenSecretLength:
+		fail("page token secret must be at least %d bytes with %s storage", minTokenSecretLength, c.Storage)
+	}
+
+	if c.Queue.Name == "" {
+		fail("queue name is empty")
+	}
+	if c.Queue.Concurrency < 0 {
+		fail("queue concurrency must not be negative")
+	}
+	if c.Queue.ResultsExpireIn < time.Second {
+		fail("queue results expiration must be at least one second")
+	}
+	if c.Queue.LocalSize < 1 {
+		fail("local queue size must be positive")
+	}
+	if c.Queue.DrainPageSize < 1 {
+		fail("queue drain page size must be positive")
+	}
+
+	if c.Retry.MaxAttempts < 1 {
+		fail("retry attempts must be positive")
+	}
+	if c.Retry.InitialBackoff <= 0 || c.Retry.MaxBackoff < c.Retry.InitialBackoff {
+		fail("retry backoff must be positive and not exceed max backoff")
+	}
+
+	if c.Health.CheckTimeout <= 0 {
+		fail("health check timeout must be positive")
+	}
+	if c.Health.WorkerPort < 1 || c.Health.WorkerPort > 65535 {
+		fail("health worker port %d is out of range", c.Health.WorkerPort)
+	}
+
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterOTLP:
+	case TracingExporterFile:
+		if c.Tracing.FilePath == "" {
+			fail("tracing file path is empty")
+		}
+	default:
+		fail("unexpected tracing exporter %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("tracing sample ratio must be in [0, 1]")
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Logging.Level)); err != nil {
+		fail("invalid log level %q", c.Logging.Level)
+	}
+	if c.Logging.Format != LogFormatJSON && c.Logging.Format != LogFormatText {
+		fail("unexpected log format %q", c.Logging.Format)
+	}
+
+	if c.Validation.MaxPostLength < 1 {
+		fail("max post length must be positive")
+	}
+	if c.Validation.MaxBodyBytes < 1 {
+		fail("max body size must be positive")
+	}
+
+	if c.Idempotency.Window <= 0 || c.Idempotency.LockTimeout <= 0 {
+		fail("idempotency window and lock timeout must be positive")
+	}
+
+	if c.RateLimit.Enabled {
+		if c.RateLimit.WriteRate <= 0 || c.RateLimit.ReadRate <= 0 {
+			fail("rate limits must be positive")
+		}
+		if c.RateLimit.WriteBurst < 1 || c.RateLimit.ReadBurst < 1 {
+			fail("rate limit bursts must be positive")
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
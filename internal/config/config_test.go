@@ -0,0 +1,235 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTokenSecret = "0123456789abcdef"
+
+// writeConfigFile points CONFIG_FILE to a new file with the content
+func writeConfigFile(t *testing.T, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+}
+
+func TestLoad(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "YAML",
+			file: "config.yaml",
+			content: `
+server:
+  port: 9000
+  readTimeout: 20s
+cache:
+  postTTL: 2h
+pagination:
+  tokenSecret: ` + testTokenSecret + `
+`,
+		},
+		{
+			name: "TOML",
+			file: "config.toml",
+			content: `
+[server]
+port = 9000
+readTimeout = "20s"
+
+[cache]
+postTTL = "2h"
+
+[pagination]
+tokenSecret = "` + testTokenSecret + `"
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			writeConfigFile(t, tc.file, tc.content)
+			t.Setenv("SERVER_PORT", "9100")
+			t.Setenv("CACHE_SUBSCRIPTIONS_TTL", "1m")
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// env variables override the file, which overrides defaults
+			want := Default()
+			want.Server.Port = 9100
+			want.Server.ReadTimeout = 20 * time.Second
+			want.Cache.PostTTL = 2 * time.Hour
+			want.Cache.SubscriptionsTTL = time.Minute
+			want.Pagination.TokenSecret = testTokenSecret
+
+			if cfg != want {
+				t.Fatalf("got %+v, want %+v", cfg, want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		want    string
+	}{
+		{name: "UnknownYAMLKey", file: "config.yaml", content: "cache:\n  postLifetime: 2h\n", want: "postLifetime"},
+		{name: "UnknownYAMLSection", file: "config.yaml", content: "caches:\n  postTTL: 2h\n", want: "caches"},
+		{name: "UnknownTOMLKey", file: "config.toml", content: "[cache]\npostLifetime = \"2h\"\n", want: "cache.postLifetime"},
+		{name: "MalformedFile", file: "config.yaml", content: "server: [", want: "failed to parse config file"},
+		{name: "UnsupportedFormat", file: "config.json", content: "{}", want: "unsupported config file format"},
+		{name: "InvalidEnv", file: "config.yaml", env: map[string]string{"SERVER_PORT": "port"}, want: "invalid value of SERVER_PORT"},
+		{name: "InvalidResult", file: "config.yaml", env: map[string]string{"APP_MODE": "CLIENT"}, want: `unexpected mode "CLIENT"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			writeConfigFile(t, tc.file, tc.content)
+			t.Setenv("PAGE_TOKEN_SECRET", testTokenSecret)
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(cfg *Config)
+		// want is a part of the error, the config is valid if it's empty
+		want string
+	}{
+		{name: "Default", modify: func(cfg *Config) {}},
+		{name: "UnknownMode", modify: func(cfg *Config) { cfg.Mode = "CLIENT" }, want: `unexpected mode "CLIENT"`},
+		{name: "UnknownStorage", modify: func(cfg *Config) { cfg.Storage = "cassandra" }, want: `unexpected storage "cassandra"`},
+		{
+			name:   "MemoryInWorkerMode",
+			modify: func(cfg *Config) { cfg.Storage, cfg.Mode = StorageMemory, ModeWorker },
+			want:   "memory storage is supported only in SERVER and ALL modes",
+		},
+		{name: "MemoryInAllMode", modify: func(cfg *Config) { cfg.Storage, cfg.Mode = StorageMemory, ModeAll }},
+		{
+			name:   "SQLiteInServerMode",
+			modify: func(cfg *Config) { cfg.Storage, cfg.Mode = StorageSQLite, ModeServer },
+			want:   "sqlite storage is supported only in ALL mode",
+		},
+		{
+			name:   "SQLiteInWorkerMode",
+			modify: func(cfg *Config) { cfg.Storage, cfg.Mode = StorageSQLite, ModeWorker },
+			want:   "sqlite storage is supported only in ALL mode",
+		},
+		{name: "SQLiteInAllMode", modify: func(cfg *Config) { cfg.Storage, cfg.Mode = StorageSQLite, ModeAll }},
+		{name: "PortOutOfRange", modify: func(cfg *Config) { cfg.Server.Port = 70000 }, want: "server port 70000 is out of range"},
+		{name: "InvalidMongoURL", modify: func(cfg *Config) { cfg.Mongo.URL = "localhost" }, want: "invalid mongo url"},
+		{name: "InvalidPostgresURL", modify: func(cfg *Config) { cfg.Postgres.URL = "localhost" }, want: "invalid postgres url"},
+		{name: "EmptySQLitePath", modify: func(cfg *Config) { cfg.SQLite.Path = "" }, want: "sqlite path is empty"},
+		{name: "RedisTimeouts", modify: func(cfg *Config) { cfg.Redis.OperationTimeout = 0 }, want: "redis timeouts must be positive"},
+		{name: "CacheTTLJitter", modify: func(cfg *Config) { cfg.Cache.TTLJitter = 1 }, want: "cache ttl jitter must be in [0, 1)"},
+		{name: "CacheLoadTimeout", modify: func(cfg *Config) { cfg.Cache.LoadTimeout = 0 }, want: "cache load timeout must be positive"},
+		{name: "CacheCodec", modify: func(cfg *Config) { cfg.Cache.Codec = "xml" }, want: `unexpected cache codec "xml"`},
+		{
+			name:   "DefaultPageSizeOverMax",
+			modify: func(cfg *Config) { cfg.Pagination.DefaultSize = cfg.Pagination.MaxSize + 1 },
+			want:   "exceeds max page size",
+		},
+		{
+			name:   "MissingTokenSecret",
+			modify: func(cfg *Config) { cfg.Pagination.TokenSecret = "" },
+			want:   "page token secret must be at least 16 bytes with mongo storage",
+		},
+		{
+			name:   "ShortTokenSecret",
+			modify: func(cfg *Config) { cfg.Pagination.TokenSecret = testTokenSecret[:15] },
+			want:   "page token secret must be at least 16 bytes",
+		},
+		{
+			name:   "MissingTokenSecretStandalone",
+			modify: func(cfg *Config) { cfg.Storage, cfg.Pagination.TokenSecret = StorageMemory, "" },
+		},
+		{
+			name:   "ShortTokenSecretStandalone",
+			modify: func(cfg *Config) { cfg.Storage, cfg.Pagination.TokenSecret = StorageMemory, testTokenSecret[:15] },
+			want:   "page token secret must be at least 16 bytes with memory storage",
+		},
+		{name: "RetryBackoff", modify: func(cfg *Config) { cfg.Retry.InitialBackoff = cfg.Retry.MaxBackoff + 1 }, want: "retry backoff"},
+		{name: "LogLevel", modify: func(cfg *Config) { cfg.Logging.Level = "loud" }, want: `invalid log level "loud"`},
+		{name: "MaxBodyBytes", modify: func(cfg *Config) { cfg.Validation.MaxBodyBytes = 0 }, want: "max body size must be positive"},
+		{
+			name: "RateLimitsWhenDisabled",
+			modify: func(cfg *Config) {
+				cfg.RateLimit.Enabled = false
+				cfg.RateLimit.WriteRate = 0
+			},
+		},
+		{
+			name: "RateLimitsWhenEnabled",
+			modify: func(cfg *Config) {
+				cfg.RateLimit.Enabled = true
+				cfg.RateLimit.WriteRate = 0
+			},
+			want: "rate limits must be positive",
+		},
+		{
+			name: "SeveralFailures",
+			modify: func(cfg *Config) {
+				cfg.Server.Port = 0
+				cfg.Queue.Name = ""
+			},
+			want: "server port 0 is out of range; queue name is empty",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Pagination.TokenSecret = testTokenSecret
+			tc.modify(&cfg)
+
+			err := cfg.Validate()
+			switch {
+			case tc.want == "" && err != nil:
+				t.Fatalf("got error %v, want none", err)
+			case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+				t.Fatalf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeRedisAddr(t *testing.T) {
+	for _, tc := range []struct {
+		addr string
+		want string
+	}{
+		{addr: "127.0.0.1:6379", want: "127.0.0.1:6379"},
+		{addr: "redis://cache:6380", want: "cache:6380"},
+		{addr: "redis://cache:6380/0", want: "cache:6380"},
+	} {
+		t.Run(tc.addr, func(t *testing.T) {
+			cfg := Default()
+			cfg.Pagination.TokenSecret = testTokenSecret
+			cfg.Redis.Addr = tc.addr
+
+			if err := cfg.Validate(); err != nil || cfg.Redis.Addr != tc.want {
+				t.Fatalf("got %q (error %v), want %q", cfg.Redis.Addr, err, tc.want)
+			}
+		})
+	}
+}
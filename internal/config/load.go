@@ -0,0 +1,216 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Load builds the config from defaults, an optional file pointed by CONFIG_FILE and env variables (in that order)
+func Load() (Config, error) {
+	cfg := Default()
+
+	if path, ok := os.LookupEnv("CONFIG_FILE"); ok && path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := loadEnv(&cfg); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func loadFile(path string, cfg *Config) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// unknown keys are rejected, so a misspelled setting doesn't silently keep its default
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(cfg); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		var meta toml.MetaData
+		if meta, err = toml.Decode(string(raw), cfg); err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys %v", meta.Undecoded())
+		}
+	default:
+		return fmt.Errorf("unsupported config file format %q", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+type envLoader struct {
+	err error
+}
+
+func (l *envLoader) string(name string, dst *string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*dst = v
+	}
+}
+
+func (l *envLoader) int(name string, dst *int) {
+	v, ok := os.LookupEnv(name)
+	if !ok || l.err != nil {
+		return
+	}
+
+	parsed, err := strconv.Atoi(v)
+	if err != nil {
+		l.err = fmt.Errorf("invalid value of %s: %w", name, err)
+		return
+	}
+	*dst = parsed
+}
+
+func (l *envLoader) duration(name string, dst *time.Duration) {
+	v, ok := os.LookupEnv(name)
+	if !ok || l.err != nil {
+		return
+	}
+
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		l.err = fmt.Errorf("invalid value of %s: %w", name, err)
+		return
+	}
+	*dst = parsed
+}
+
+func (l *envLoader) float(name string, dst *float64) {
+	v, ok := os.LookupEnv(name)
+	if !ok || l.err != nil {
+		return
+	}
+
+	parsed, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		l.err = fmt.Errorf("invalid value of %s: %w", name, err)
+		return
+	}
+	*dst = parsed
+}
+
+func (l *envLoader) bool(name string, dst *bool) {
+	v, ok := os.LookupEnv(name)
+	if !ok || l.err != nil {
+		return
+	}
+
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		l.err = fmt.Errorf("invalid value of %s: %w", name, err)
+		return
+	}
+	*dst = parsed
+}
+
+func loadEnv(cfg *Config) error {
+	l := &envLoader{}
+
+	l.string("APP_MODE", &cfg.Mode)
+	l.string("STORAGE", &cfg.Storage)
+
+	l.int("SERVER_PORT", &cfg.Server.Port)
+	l.duration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	l.duration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+
+	l.string("MONGO_URL", &cfg.Mongo.URL)
+	l.string("MONGO_DBNAME", &cfg.Mongo.DBName)
+	l.duration("MONGO_CONNECT_TIMEOUT", &cfg.Mongo.ConnectTimeout)
+	l.duration("MONGO_INDEX_TIMEOUT", &cfg.Mongo.IndexTimeout)
+
+	l.string("POSTGRES_URL", &cfg.Postgres.URL)
+	l.duration("POSTGRES_CONNECT_TIMEOUT", &cfg.Postgres.ConnectTimeout)
+	l.duration("POSTGRES_MIGRATION_TIMEOUT", &cfg.Postgres.MigrationTimeout)
+
+	l.string("SQLITE_PATH", &cfg.SQLite.Path)
+	l.duration("SQLITE_BUSY_TIMEOUT", &cfg.SQLite.BusyTimeout)
+
+	l.string("REDIS_URL", &cfg.Redis.Addr)
+	l.duration("REDIS_CONNECT_TIMEOUT", &cfg.Redis.ConnectTimeout)
+	l.duration("REDIS_OPERATION_TIMEOUT", &cfg.Redis.OperationTimeout)
+	l.int("REDIS_BREAKER_THRESHOLD", &cfg.Redis.BreakerThreshold)
+	l.duration("REDIS_BREAKER_COOLDOWN", &cfg.Redis.BreakerCooldown)
+	l.int("REDIS_INVALIDATION_QUEUE_SIZE", &cfg.Redis.InvalidationQueueSize)
+
+	l.duration("CACHE_POST_TTL", &cfg.Cache.PostTTL)
+	l.duration("CACHE_PAGE_TTL", &cfg.Cache.PageTTL)
+	l.duration("CACHE_SUBSCRIPTIONS_TTL", &cfg.Cache.SubscriptionsTTL)
+	l.duration("CACHE_HYDRATED_FEED_TTL", &cfg.Cache.HydratedFeedTTL)
+	l.int("CACHE_TIMELINE_LENGTH", &cfg.Cache.TimelineLength)
+	l.duration("CACHE_NEGATIVE_TTL", &cfg.Cache.NegativeTTL)
+	l.float("CACHE_TTL_JITTER", &cfg.Cache.TTLJitter)
+	l.duration("CACHE_EARLY_REFRESH", &cfg.Cache.EarlyRefresh)
+	l.duration("CACHE_LOAD_TIMEOUT", &cfg.Cache.LoadTimeout)
+	l.duration("CACHE_RECONCILE_INTERVAL", &cfg.Cache.ReconcileInterval)
+	l.string("CACHE_CODEC", &cfg.Cache.Codec)
+	l.string("CACHE_PAGE_COMPRESSION", &cfg.Cache.PageCompression)
+
+	l.int("PAGE_DEFAULT_SIZE", &cfg.Pagination.DefaultSize)
+	l.int("PAGE_MAX_SIZE", &cfg.Pagination.MaxSize)
+	l.int("PAGE_MAX_UNREAD_COUNT", &cfg.Pagination.MaxUnreadCount)
+	l.string("PAGE_TOKEN_SECRET", &cfg.Pagination.TokenSecret)
+
+	l.string("QUEUE_NAME", &cfg.Queue.Name)
+	l.string("QUEUE_CONSUMER_TAG", &cfg.Queue.ConsumerTag)
+	l.int("QUEUE_CONCURRENCY", &cfg.Queue.Concurrency)
+	l.duration("QUEUE_RESULTS_EXPIRE_IN", &cfg.Queue.ResultsExpireIn)
+	l.int("QUEUE_DRAIN_PAGE_SIZE", &cfg.Queue.DrainPageSize)
+	l.int("QUEUE_LOCAL_SIZE", &cfg.Queue.LocalSize)
+
+	l.int("RETRY_MAX_ATTEMPTS", &cfg.Retry.MaxAttempts)
+	l.duration("RETRY_INITIAL_BACKOFF", &cfg.Retry.InitialBackoff)
+	l.duration("RETRY_MAX_BACKOFF", &cfg.Retry.MaxBackoff)
+
+	l.duration("HEALTH_CHECK_TIMEOUT", &cfg.Health.CheckTimeout)
+	l.int("HEALTH_WORKER_PORT", &cfg.Health.WorkerPort)
+
+	l.string("TRACING_EXPORTER", &cfg.Tracing.Exporter)
+	l.string("TRACING_OTLP_ENDPOINT", &cfg.Tracing.OTLPEndpoint)
+	l.string("TRACING_FILE", &cfg.Tracing.FilePath)
+	l.string("TRACING_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	l.float("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio)
+
+	l.string("LOG_LEVEL", &cfg.Logging.Level)
+	l.string("LOG_FORMAT", &cfg.Logging.Format)
+
+	l.int("POST_MAX_LENGTH", &cfg.Validation.MaxPostLength)
+	l.int("REQUEST_MAX_BODY_BYTES", &cfg.Validation.MaxBodyBytes)
+
+	l.duration("IDEMPOTENCY_WINDOW", &cfg.Idempotency.Window)
+	l.duration("IDEMPOTENCY_LOCK_TIMEOUT", &cfg.Idempotency.LockTimeout)
+
+	l.bool("RATE_LIMIT_ENABLED", &cfg.RateLimit.Enabled)
+	l.float("RATE_LIMIT_WRITE_RATE", &cfg.RateLimit.WriteRate)
+	l.int("RATE_LIMIT_WRITE_BURST", &cfg.RateLimit.WriteBurst)
+	l.float("RATE_LIMIT_READ_RATE", &cfg.RateLimit.ReadRate)
+	l.int("RATE_LIMIT_READ_BURST", &cfg.RateLimit.ReadBurst)
+	l.bool("RATE_LIMIT_TRUST_PROXY", &cfg.RateLimit.TrustProxy)
+
+	l.bool("OPENAPI_VALIDATE_REQUESTS", &cfg.OpenAPI.ValidateRequests)
+	l.bool("OPENAPI_VALIDATE_RESPONSES", &cfg.OpenAPI.ValidateResponses)
+
+	return l.err
+}
@@ -0,0 +1,78 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"microblog/internal/config"
+	"time"
+)
+
+// Record is a response stored for an idempotency key. Record without status belongs to a request in progress
+type Record struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+func (r Record) Completed() bool {
+	return r.Status != 0
+}
+
+type Store interface {
+	// Begin claims the key for a request with given fingerprint. If the key is already claimed, stored record is returned
+	Begin(ctx context.Context, key string, fingerprint string) (Record, bool, error)
+	// Complete stores the response for the replay window
+	Complete(ctx context.Context, key string, record Record) error
+	// Release forgets the key, so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+var _ Store = (*RedisStore)(nil)
+
+type RedisStore struct {
+	client      *redis.Client
+	window      time.Duration
+	lockTimeout time.Duration
+}
+
+func NewRedisStore(client *redis.Client, cfg config.IdempotencyConfig) *RedisStore {
+	return &RedisStore{client: client, window: cfg.Window, lockTimeout: cfg.LockTimeout}
+}
+
+func (s *RedisStore) Begin(ctx context.Context, key string, fingerprint string) (Record, bool, error) {
+	serialized, _ := json.Marshal(Record{Fingerprint: fingerprint})
+
+	claimed, err := s.client.SetNX(ctx, key, serialized, s.lockTimeout).Result()
+	if err != nil || claimed {
+		return Record{}, claimed, err
+	}
+
+	raw, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// the key has just expired, try once more
+		claimed, err = s.client.SetNX(ctx, key, serialized, s.lockTimeout).Result()
+		return Record{}, claimed, err
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	var record Record
+	err = json.Unmarshal(raw, &record)
+	return record, false, err
+}
+
+func (s *RedisStore) Complete(ctx context.Context, key string, record Record) error {
+	serialized, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, key, serialized, s.window).Err()
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, key).Err()
+}
@@ -0,0 +1,79 @@
+package idempotency
+
+import (
+	"context"
+	"microblog/internal/config"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore keeps records in process memory, it is used when there is no redis (e.g. memory storage)
+type MemoryStore struct {
+	mu          sync.Mutex
+	records     map[string]memoryRecord
+	window      time.Duration
+	lockTimeout time.Duration
+	now         func() time.Time
+	lastSweep   time.Time
+}
+
+type memoryRecord struct {
+	record  Record
+	expires time.Time
+}
+
+func NewMemoryStore(cfg config.IdempotencyConfig) *MemoryStore {
+	return &MemoryStore{
+		records:     make(map[string]memoryRecord),
+		window:      cfg.Window,
+		lockTimeout: cfg.LockTimeout,
+		now:         time.Now,
+	}
+}
+
+func (s *MemoryStore) Begin(_ context.Context, key string, fingerprint string) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if stored, ok := s.records[key]; ok && now.Before(stored.expires) {
+		return stored.record, false, nil
+	}
+
+	s.records[key] = memoryRecord{record: Record{Fingerprint: fingerprint}, expires: now.Add(s.lockTimeout)}
+	return Record{}, true, nil
+}
+
+func (s *MemoryStore) Complete(_ context.Context, key string, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = memoryRecord{record: record, expires: s.now().Add(s.window)}
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// sweep forgets expired records at most once a minute
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, stored := range s.records {
+		if !now.Before(stored.expires) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"microblog/internal/config"
+	"strings"
+	"testing"
+)
+
+func TestContextHandler(t *testing.T) {
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x0a, 0x0b, 0x0c},
+		SpanID:  trace.SpanID{0x01},
+	})
+	ctx := trace.ContextWithSpanContext(WithRequestId(context.Background(), "request-1"), spanContext)
+
+	for _, tc := range []struct {
+		name string
+		log  func(logger *slog.Logger)
+		want map[string]any
+	}{
+		{
+			name: "Context",
+			log:  func(logger *slog.Logger) { logger.InfoContext(ctx, "message") },
+			want: map[string]any{"request_id": "request-1", "trace_id": spanContext.TraceID().String()},
+		},
+		{
+			name: "WithAttrs",
+			log:  func(logger *slog.Logger) { logger.With("key", "value").InfoContext(ctx, "message") },
+			want: map[string]any{"key": "value", "request_id": "request-1", "trace_id": spanContext.TraceID().String()},
+		},
+		{
+			name: "RequestIdOnly",
+			log: func(logger *slog.Logger) {
+				logger.InfoContext(WithRequestId(context.Background(), "request-1"), "message")
+			},
+			want: map[string]any{"request_id": "request-1", "trace_id": nil},
+		},
+		{
+			name: "WithoutContext",
+			log:  func(logger *slog.Logger) { logger.Info("message") },
+			want: map[string]any{"request_id": nil, "trace_id": nil},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(config.LoggingConfig{Level: "info", Format: config.LogFormatJSON}, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.log(logger)
+
+			var record map[string]any
+			if err = json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("%v in %q", err, buf.String())
+			}
+			for key, want := range tc.want {
+				if got := record[key]; got != want {
+					t.Fatalf("got %s %v, want %v in %s", key, got, want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  config.LoggingConfig
+		want string
+	}{
+		{name: "Level", cfg: config.LoggingConfig{Level: "loud", Format: config.LogFormatJSON}, want: `invalid log level "loud"`},
+		{name: "Format", cfg: config.LoggingConfig{Level: "info", Format: "xml"}, want: `unexpected log format "xml"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := New(tc.cfg, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	logger, err := New(config.LoggingConfig{Level: "warn", Format: config.LogFormatText}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.InfoContext(WithRequestId(context.Background(), "request-1"), "skipped")
+	logger.WarnContext(WithRequestId(context.Background(), "request-2"), "logged")
+	if got := buf.String(); strings.Contains(got, "skipped") || !strings.Contains(got, "request_id=request-2") {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// MachineryLogger adapts slog to the logger interface used inside machinery
+type MachineryLogger struct {
+	Level slog.Level
+}
+
+func (l MachineryLogger) log(msg string) {
+	slog.Log(context.Background(), l.Level, msg, slog.String("component", "machinery"))
+}
+
+func (l MachineryLogger) Print(args ...interface{}) {
+	l.log(fmt.Sprint(args...))
+}
+
+func (l MachineryLogger) Printf(format string, args ...interface{}) {
+	l.log(fmt.Sprintf(format, args...))
+}
+
+func (l MachineryLogger) Println(args ...interface{}) {
+	l.log(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+func (l MachineryLogger) Fatal(args ...interface{}) {
+	l.Print(args...)
+	os.Exit(1)
+}
+
+func (l MachineryLogger) Fatalf(format string, args ...interface{}) {
+	l.Printf(format, args...)
+	os.Exit(1)
+}
+
+func (l MachineryLogger) Fatalln(args ...interface{}) {
+	l.Println(args...)
+	os.Exit(1)
+}
+
+func (l MachineryLogger) Panic(args ...interface{}) {
+	msg := fmt.Sprint(args...)
+	l.log(msg)
+	panic(msg)
+}
+
+func (l MachineryLogger) Panicf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	l.log(msg)
+	panic(msg)
+}
+
+func (l MachineryLogger) Panicln(args ...interface{}) {
+	msg := strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+	l.log(msg)
+	panic(msg)
+}
@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const namespace = "microblog"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of handled HTTP requests per route.",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of handled HTTP requests per route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Number of redis cache lookups per key family and result (hit or miss).",
+	}, []string{"family", "result"})
+
+	cacheBypassed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "bypassed_total",
+		Help:      "Number of reads served by the persistent repository because redis is unavailable, per key family.",
+	}, []string{"family"})
+
+	cacheBreakerOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "breaker_open",
+		Help:      "Whether the redis circuit breaker is open (1) or closed (0).",
+	})
+
+	cacheInvalidationsPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "invalidations_pending",
+		Help:      "Number of failed cache invalidations waiting for replay.",
+	})
+
+	cacheRepairs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "repairs_total",
+		Help:      "Number of cached entries dropped by reconciliation because they differ from the persistent repository, per key family.",
+	}, []string{"family"})
+
+	mongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of mongo repository operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	postgresDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "postgres",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of postgres repository operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	sqliteDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sqlite",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of sqlite repository operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	localQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "worker",
+		Name:      "local_queue_length",
+		Help:      "Number of tasks waiting in the in-process queue of ALL mode.",
+	})
+
+	tasksProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "worker",
+		Name:      "tasks_total",
+		Help:      "Number of processed background tasks per status (success or failure).",
+	}, []string{"task", "status"})
+
+	taskDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "worker",
+		Name:      "task_duration_seconds",
+		Help:      "Duration of background tasks.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 4, 8),
+	}, []string{"task"})
+
+	fanOutSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "worker",
+		Name:      "fan_out_size",
+		Help:      "Number of feeds updated by a single background task.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	}, []string{"task"})
+)
+
+func ObserveHTTPRequest(route, method string, code int, start time.Time) {
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(code)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCacheLookup uses the prefix of redis key (e.g. "post" for "post:<id>") as a key family
+func ObserveCacheLookup(key string, hit bool) {
+	family, _, _ := strings.Cut(key, ":")
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(family, result).Inc()
+}
+
+func ObserveCacheBypass(key string) {
+	family, _, _ := strings.Cut(key, ":")
+	cacheBypassed.WithLabelValues(family).Inc()
+}
+
+func ObserveCacheRepair(key string) {
+	family, _, _ := strings.Cut(key, ":")
+	cacheRepairs.WithLabelValues(family).Inc()
+}
+
+func SetCacheBreakerOpen(open bool) {
+	value := 0.0
+	if open {
+		value = 1
+	}
+	cacheBreakerOpen.Set(value)
+}
+
+func SetCacheInvalidationsPending(count int) {
+	cacheInvalidationsPending.Set(float64(count))
+}
+
+func ObserveMongoOperation(operation string, start time.Time) {
+	mongoDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func ObservePostgresOperation(operation string, start time.Time) {
+	postgresDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func ObserveSQLiteOperation(operation string, start time.Time) {
+	sqliteDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func ObserveTask(task string, start time.Time, err error) {
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	tasksProcessed.WithLabelValues(task, status).Inc()
+	taskDuration.WithLabelValues(task).Observe(time.Since(start).Seconds())
+}
+
+func ObserveFanOut(task string, size int) {
+	fanOutSize.WithLabelValues(task).Observe(float64(size))
+}
+
+func SetLocalQueueLength(length int) {
+	localQueueLength.Set(float64(length))
+}
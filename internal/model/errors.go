@@ -5,4 +5,36 @@ import "errors"
 var PostCreationFailed = errors.New("post_generation_failed")
 var PostNotFound = errors.New("post_not_found")
 var InvalidPageToken = errors.New("invalid_page_token")
+var InvalidPageCursor = errors.New("invalid_page_cursor")
 var AlreadySubscribed = errors.New("already_subscribed")
+var SelfSubscription = errors.New("self_subscription")
+var InvalidUserId = errors.New("invalid_user_id")
+var InvalidPageSize = errors.New("invalid_page_size")
+var InvalidRequestBody = errors.New("invalid_request_body")
+var NotPostAuthor = errors.New("not_post_author")
+var RouteNotFound = errors.New("route_not_found")
+var MethodNotAllowed = errors.New("method_not_allowed")
+var InvalidIdempotencyKey = errors.New("invalid_idempotency_key")
+var IdempotencyKeyReused = errors.New("idempotency_key_reused")
+var IdempotentRequestInProgress = errors.New("idempotent_request_in_progress")
+var IdempotencyUnavailable = errors.New("idempotency_unavailable")
+var RateLimitExceeded = errors.New("rate_limit_exceeded")
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError describes all problems of the request body, it is treated as InvalidRequestBody by errors.Is
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return InvalidRequestBody.Error()
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == InvalidRequestBody
+}
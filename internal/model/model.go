@@ -5,6 +5,8 @@ import "go.mongodb.org/mongo-driver/bson/primitive"
 type PostId string
 type UserId string
 type ISOTimestamp string
+
+// PageToken is a storage cursor in repositories, clients get it wrapped into a signed token by pagination.Signer
 type PageToken string
 
 type Post struct {
@@ -17,3 +19,40 @@ type Post struct {
 }
 
 const EmptyPage = PageToken("none")
+
+type Direction byte
+
+const (
+	// DirectionOlder pages go from the cursor to the oldest item
+	DirectionOlder Direction = iota + 1
+	// DirectionNewer pages go from the cursor to the newest item, they are used to poll for new items
+	DirectionNewer
+)
+
+// PageRequest selects up to Size items next to Cursor in a list ordered from the newest to the oldest item.
+// EmptyPage cursor means the newest items in any direction
+type PageRequest struct {
+	Cursor    PageToken
+	Direction Direction
+	Size      int
+}
+
+// PageCursors are storage cursors of pages adjacent to the returned one. Older is EmptyPage if there are no older
+// items, Newer is set whenever the page has a position, since newer items may appear there later
+type PageCursors struct {
+	Older PageToken
+	Newer PageToken
+}
+
+// FeedPage is a page of the feed with resolved posts, Dangling are ids of feed items whose posts do not exist
+type FeedPage struct {
+	Posts    []Post
+	Cursors  PageCursors
+	Dangling []PostId
+}
+
+// TimelinePage is a cached page of a list beyond its timeline, posts are resolved by ids when it's served
+type TimelinePage struct {
+	Ids     []PostId
+	Cursors PageCursors
+}
@@ -0,0 +1,111 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"log/slog"
+	"microblog/internal/model"
+)
+
+// version of the token layout, tokens of other versions are rejected
+const version byte = 1
+
+// signatureSize is the length of truncated HMAC-SHA256, 128 bits are enough to make forging impractical
+const signatureSize = 16
+
+type List byte
+
+const (
+	ListPosts List = iota + 1
+	ListFeed
+)
+
+// Page is the content of a token: a storage cursor in the list of the owner
+type Page struct {
+	List      List
+	Owner     model.UserId
+	Cursor    model.PageToken
+	Direction model.Direction
+}
+
+// Signer wraps storage cursors into opaque tokens which can be checked without a database round trip.
+// Layout before base64url: version, list, direction, owner and cursor prefixed by their lengths, signature
+type Signer struct {
+	key []byte
+}
+
+// NewSigner creates a signer with the secret, an empty secret is replaced by a random one,
+// so tokens survive neither restarts nor switching between replicas. Config allows it only for standalone storages
+func NewSigner(secret string) *Signer {
+	key := []byte(secret)
+	if len(key) == 0 {
+		slog.Warn("Page token secret is not configured, using a random one")
+		key = make([]byte, 32)
+		_, _ = rand.Read(key)
+	}
+
+	return &Signer{key: key}
+}
+
+func (s *Signer) Sign(page Page) model.PageToken {
+	payload := []byte{version, byte(page.List), byte(page.Direction)}
+	payload = binary.AppendUvarint(payload, uint64(len(page.Owner)))
+	payload = append(payload, page.Owner...)
+	payload = binary.AppendUvarint(payload, uint64(len(page.Cursor)))
+	payload = append(payload, page.Cursor...)
+
+	return model.PageToken(base64.RawURLEncoding.EncodeToString(append(payload, s.signature(payload)...)))
+}
+
+// Verify returns the page of the token if it is signed by this signer and issued for the list of the owner
+func (s *Signer) Verify(token model.PageToken, list List, owner model.UserId) (Page, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(string(token))
+	if err != nil || len(raw) < signatureSize+3 {
+		return Page{}, model.InvalidPageToken
+	}
+
+	payload, signature := raw[:len(raw)-signatureSize], raw[len(raw)-signatureSize:]
+	if !hmac.Equal(signature, s.signature(payload)) || payload[0] != version {
+		return Page{}, model.InvalidPageToken
+	}
+
+	page := Page{List: List(payload[1]), Direction: model.Direction(payload[2])}
+	rest := payload[3:]
+
+	ownerValue, rest, ok := readBytes(rest)
+	if !ok {
+		return Page{}, model.InvalidPageToken
+	}
+	cursor, rest, ok := readBytes(rest)
+	if !ok || len(rest) != 0 {
+		return Page{}, model.InvalidPageToken
+	}
+	page.Owner = model.UserId(ownerValue)
+	page.Cursor = model.PageToken(cursor)
+
+	validDirection := page.Direction == model.DirectionOlder || page.Direction == model.DirectionNewer
+	if page.List != list || page.Owner != owner || !validDirection {
+		return Page{}, model.InvalidPageToken
+	}
+
+	return page, nil
+}
+
+func (s *Signer) signature(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)[:signatureSize]
+}
+
+// readBytes reads a length-prefixed value
+func readBytes(b []byte) ([]byte, []byte, bool) {
+	n, read := binary.Uvarint(b)
+	if read <= 0 || n > uint64(len(b)-read) {
+		return nil, nil, false
+	}
+	b = b[read:]
+	return b[:n], b[n:], true
+}
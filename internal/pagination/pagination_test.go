@@ -0,0 +1,48 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"microblog/internal/model"
+	"testing"
+)
+
+func TestSigner(t *testing.T) {
+	signer := NewSigner("0123456789abcdef")
+	page := Page{List: ListFeed, Owner: "aa", Cursor: "65f1c0de0000000000000001", Direction: model.DirectionNewer}
+	token := signer.Sign(page)
+
+	got, err := signer.Verify(token, ListFeed, "aa")
+	if err != nil || got != page {
+		t.Fatalf("got %+v, %v, want %+v", got, err, page)
+	}
+
+	raw, _ := base64.RawURLEncoding.DecodeString(string(token))
+	tampered := append([]byte{}, raw...)
+	tampered[len(tampered)-signatureSize-1] ^= 1
+	unsigned := append([]byte{}, raw[:len(raw)-signatureSize]...)
+	unsigned[0] = version + 1
+
+	for _, tc := range []struct {
+		name  string
+		token model.PageToken
+		list  List
+		owner model.UserId
+	}{
+		{name: "OtherOwner", token: token, list: ListFeed, owner: "bb"},
+		{name: "OtherList", token: token, list: ListPosts, owner: "aa"},
+		{name: "OtherSecret", token: NewSigner("fedcba9876543210").Sign(page), list: ListFeed, owner: "aa"},
+		{name: "Tampered", token: model.PageToken(base64.RawURLEncoding.EncodeToString(tampered)), list: ListFeed, owner: "aa"},
+		{name: "OtherVersion", token: model.PageToken(base64.RawURLEncoding.EncodeToString(
+			append(unsigned, signer.signature(unsigned)...))), list: ListFeed, owner: "aa"},
+		{name: "Truncated", token: token[:10], list: ListFeed, owner: "aa"},
+		{name: "RawCursor", token: page.Cursor, list: ListFeed, owner: "aa"},
+		{name: "NotBase64", token: "not*base64", list: ListFeed, owner: "aa"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := signer.Verify(tc.token, tc.list, tc.owner); !errors.Is(err, model.InvalidPageToken) {
+				t.Fatalf("got error %v, want %v", err, model.InvalidPageToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Limit describes a token bucket: Rate tokens are added per second up to Burst
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+var _ Limiter = (*RedisLimiter)(nil)
+
+// tokenBucketScript atomically refills the bucket stored in a hash and takes a single token from it
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate))
+
+return {allowed, tostring(tokens)}
+`)
+
+type RedisLimiter struct {
+	client *redis.Client
+	now    func() time.Time
+}
+
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{client: client, now: time.Now}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	ratePerMs := limit.Rate / 1000
+
+	raw, err := tokenBucketScript.Run(ctx, l.client, []string{key},
+		strconv.FormatFloat(ratePerMs, 'f', -1, 64),
+		limit.Burst,
+		l.now().UnixMilli(),
+	).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	allowed, _ := raw[0].(int64)
+	tokensRaw, _ := raw[1].(string)
+	tokens, err := strconv.ParseFloat(tokensRaw, 64)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Allowed:   allowed == 1,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     msToDuration((float64(limit.Burst) - tokens) / ratePerMs),
+	}
+	if !result.Allowed {
+		result.RetryAfter = msToDuration((1 - tokens) / ratePerMs)
+	}
+
+	return result, nil
+}
+
+func msToDuration(ms float64) time.Duration {
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"testing"
+	"time"
+)
+
+type step struct {
+	// advance moves the clock before the request
+	advance time.Duration
+	want    Result
+}
+
+func allowed(remaining int, reset time.Duration) step {
+	return step{want: Result{Allowed: true, Limit: 4, Remaining: remaining, Reset: reset}}
+}
+
+func denied(retryAfter, reset time.Duration) step {
+	return step{want: Result{Limit: 4, RetryAfter: retryAfter, Reset: reset}}
+}
+
+func after(advance time.Duration, s step) step {
+	s.advance = advance
+	return s
+}
+
+// TestLimiters runs the same requests against both limiters, so headers don't depend on the storage
+func TestLimiters(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 4}
+	ms := time.Millisecond
+
+	for _, tc := range []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "Burst",
+			steps: []step{
+				allowed(3, 500*ms),
+				allowed(2, 1000*ms),
+				allowed(1, 1500*ms),
+				allowed(0, 2000*ms),
+				denied(500*ms, 2000*ms),
+				denied(500*ms, 2000*ms),
+			},
+		},
+		{
+			name: "RefillAfterPartialDrain",
+			steps: []step{
+				allowed(3, 500*ms),
+				allowed(2, 1000*ms),
+				after(250*ms, allowed(1, 1250*ms)),
+				after(500*ms, allowed(1, 1250*ms)),
+				allowed(0, 1750*ms),
+				denied(250*ms, 1750*ms),
+				after(250*ms, allowed(0, 2000*ms)),
+				denied(500*ms, 2000*ms),
+			},
+		},
+		{
+			name: "RefillIsCappedByBurst",
+			steps: []step{
+				allowed(3, 500*ms),
+				after(time.Hour, allowed(3, 500*ms)),
+				allowed(2, 1000*ms),
+				allowed(1, 1500*ms),
+				allowed(0, 2000*ms),
+				denied(500*ms, 2000*ms),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+			t.Cleanup(func() { _ = client.Close() })
+
+			now := time.UnixMilli(1_700_000_000_000)
+			clock := func() time.Time { return now }
+
+			memory := NewMemoryLimiter()
+			memory.now = clock
+			redisLimiter := NewRedisLimiter(client)
+			redisLimiter.now = clock
+
+			for i, s := range tc.steps {
+				now = now.Add(s.advance)
+
+				for name, limiter := range map[string]Limiter{"memory": memory, "redis": redisLimiter} {
+					got, err := limiter.Allow(context.Background(), "key", limit)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got != s.want {
+						t.Fatalf("request %d of the %s limiter: got %+v, want %+v", i, name, got, s.want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"log/slog"
+	"microblog/internal/metrics"
+	"sync"
+)
+
+// invalidationQueue keeps keys whose invalidation failed, they are deleted before the cache is read again.
+// Keys are versioned, so a key queued again during a replay is not lost
+type invalidationQueue struct {
+	mu       sync.Mutex
+	keys     map[string]uint64
+	version  uint64
+	capacity int
+}
+
+func newInvalidationQueue(capacity int) *invalidationQueue {
+	return &invalidationQueue{keys: make(map[string]uint64), capacity: capacity}
+}
+
+func (q *invalidationQueue) add(keys ...string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dropped := 0
+	for _, key := range keys {
+		if _, queued := q.keys[key]; !queued && len(q.keys) >= q.capacity {
+			dropped++
+			continue
+		}
+		q.version++
+		q.keys[key] = q.version
+	}
+
+	if dropped > 0 {
+		slog.Warn("Cache invalidation queue is full, entries may be stale until they expire", slog.Int("dropped", dropped))
+	}
+	metrics.SetCacheInvalidationsPending(len(q.keys))
+}
+
+// replay deletes queued keys, it fails if they can't be deleted
+func (q *invalidationQueue) replay(ctx context.Context, client *redis.Client) error {
+	q.mu.Lock()
+	if len(q.keys) == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	snapshot := make(map[string]uint64, len(q.keys))
+	keys := make([]string, 0, len(q.keys))
+	for key, version := range q.keys {
+		snapshot[key] = version
+		keys = append(keys, key)
+	}
+	q.mu.Unlock()
+
+	if err := client.Del(ctx, keys...).Err(); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for key, version := range snapshot {
+		if q.keys[key] == version {
+			delete(q.keys, key)
+		}
+	}
+	metrics.SetCacheInvalidationsPending(len(q.keys))
+
+	slog.InfoContext(ctx, "Replayed cache invalidations", slog.Int("count", len(keys)))
+	return nil
+}
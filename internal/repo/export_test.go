@@ -0,0 +1,7 @@
+package repo
+
+import "context"
+
+func DropMongoDatabase(ctx context.Context, r Repository) error {
+	return r.(*MongoDatabaseRepository).posts.Database().Drop(ctx)
+}
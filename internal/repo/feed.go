@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"context"
+	"microblog/internal/model"
+)
+
+// hydrateFeed resolves posts of the feed page with GetPostsByIds of the repository, keeping the feed order
+func hydrateFeed(ctx context.Context, r Repository, id model.UserId, page model.PageRequest) (model.FeedPage, error) {
+	items, cursors, err := r.GetFeed(ctx, id, page)
+	if err != nil {
+		return model.FeedPage{}, err
+	}
+
+	ids := make([]model.PostId, len(items))
+	for i, item := range items {
+		ids[i] = item.PostId
+	}
+
+	posts, err := r.GetPostsByIds(ctx, ids)
+	if err != nil {
+		return model.FeedPage{}, err
+	}
+
+	result := model.FeedPage{Posts: posts, Cursors: cursors}
+	// posts keep the order of ids, so the missing ones are found in a single pass
+	for i, j := 0, 0; i < len(ids); i++ {
+		if j < len(posts) && posts[j].Id == ids[i] {
+			j++
+		} else {
+			result.Dangling = append(result.Dangling, ids[i])
+		}
+	}
+
+	return result, nil
+}
+
+// orderPosts arranges found posts in the order of ids, ids may repeat
+func orderPosts(ids []model.PostId, found []model.Post) []model.Post {
+	byId := make(map[model.PostId]model.Post, len(found))
+	for _, post := range found {
+		byId[post.Id] = post
+	}
+
+	result := make([]model.Post, 0, len(ids))
+	for _, id := range ids {
+		if post, ok := byId[id]; ok {
+			result = append(result, post)
+		}
+	}
+	return result
+}
@@ -0,0 +1,295 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"microblog/internal/model"
+	"microblog/internal/utils"
+	"sort"
+	"sync"
+)
+
+var _ Repository = (*MemoryRepository)(nil)
+
+// MemoryRepository keeps everything in process memory and mirrors semantics of MongoDatabaseRepository.
+// It is intended for tests and local development
+type MemoryRepository struct {
+	mu sync.RWMutex
+
+	posts map[model.PostId]model.Post
+	// postsByAuthor and feeds are ordered by token in descending order
+	postsByAuthor map[model.UserId][]model.Post
+	feeds         map[model.UserId][]model.FeedMetadataDocument
+	following     map[model.UserId][]model.UserId
+	followed      map[model.UserId][]model.UserId
+}
+
+func NewMemoryRepository() Repository {
+	return &MemoryRepository{
+		posts:         make(map[model.PostId]model.Post),
+		postsByAuthor: make(map[model.UserId][]model.Post),
+		feeds:         make(map[model.UserId][]model.FeedMetadataDocument),
+		following:     make(map[model.UserId][]model.UserId),
+		followed:      make(map[model.UserId][]model.UserId),
+	}
+}
+
+func (storage *MemoryRepository) CreatePost(_ context.Context, id model.UserId, post model.Post) (model.Post, error) {
+	post.Token = primitive.NewObjectID()
+	post.Id = model.PostId(post.Token.Hex())
+	post.AuthorId = id
+
+	now := utils.Now()
+	post.CreatedAt = now
+	post.LastModifiedAt = now
+
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	storage.posts[post.Id] = post
+
+	posts := storage.postsByAuthor[id]
+	i := sort.Search(len(posts), func(i int) bool {
+		return compareTokens(posts[i].Token, post.Token) < 0
+	})
+	posts = append(posts, model.Post{})
+	copy(posts[i+1:], posts[i:])
+	posts[i] = post
+	storage.postsByAuthor[id] = posts
+
+	return post, nil
+}
+
+func (storage *MemoryRepository) EditPost(_ context.Context, _ model.UserId, post model.Post) (model.Post, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	result, ok := storage.posts[post.Id]
+	if !ok {
+		return model.Post{}, model.PostNotFound
+	}
+
+	result.Text = post.Text
+	result.LastModifiedAt = utils.Now()
+	storage.posts[post.Id] = result
+
+	posts := storage.postsByAuthor[result.AuthorId]
+	for i := range posts {
+		if posts[i].Id == result.Id {
+			posts[i] = result
+			break
+		}
+	}
+
+	return result, nil
+}
+
+func (storage *MemoryRepository) GetPostById(_ context.Context, id model.PostId) (model.Post, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	post, ok := storage.posts[id]
+	if !ok {
+		return model.Post{}, model.PostNotFound
+	}
+
+	return post, nil
+}
+
+func (storage *MemoryRepository) GetPostsByIds(_ context.Context, ids []model.PostId) ([]model.Post, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	result := make([]model.Post, 0, len(ids))
+	for _, id := range ids {
+		if post, ok := storage.posts[id]; ok {
+			result = append(result, post)
+		}
+	}
+
+	return result, nil
+}
+
+func (storage *MemoryRepository) GetPosts(_ context.Context, id model.UserId, page model.PageRequest) ([]model.Post, model.PageCursors, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	posts := storage.postsByAuthor[id]
+
+	indexes, err := pageIndexes(page, len(posts), func(i int) primitive.ObjectID { return posts[i].Token })
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	result := make([]model.Post, 0, len(indexes))
+	for _, i := range indexes {
+		result = append(result, posts[i])
+	}
+
+	result, cursors := pageOf(result, page, func(post model.Post) primitive.ObjectID { return post.Token })
+	return result, cursors, nil
+}
+
+func (storage *MemoryRepository) Subscribe(_ context.Context, subscriberId model.UserId, targetId model.UserId) error {
+	if subscriberId == targetId {
+		return model.SelfSubscription
+	}
+
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	for _, id := range storage.followed[targetId] {
+		if id == subscriberId {
+			return model.AlreadySubscribed
+		}
+	}
+
+	storage.followed[targetId] = append(storage.followed[targetId], subscriberId)
+	storage.following[subscriberId] = append(storage.following[subscriberId], targetId)
+
+	return nil
+}
+
+func (storage *MemoryRepository) GetSubscriptions(_ context.Context, id model.UserId) ([]model.UserId, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	return append([]model.UserId{}, storage.following[id]...), nil
+}
+
+func (storage *MemoryRepository) GetSubscribers(_ context.Context, id model.UserId) ([]model.UserId, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	return append([]model.UserId{}, storage.followed[id]...), nil
+}
+
+func (storage *MemoryRepository) GetFeed(_ context.Context, id model.UserId, page model.PageRequest) ([]model.FeedMetadataDocument, model.PageCursors, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	feed := storage.feeds[id]
+
+	indexes, err := pageIndexes(page, len(feed), func(i int) primitive.ObjectID { return feed[i].Token })
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	result := make([]model.FeedMetadataDocument, 0, len(indexes))
+	for _, i := range indexes {
+		result = append(result, feed[i])
+	}
+
+	result, cursors := pageOf(result, page, func(item model.FeedMetadataDocument) primitive.ObjectID { return item.Token })
+	return result, cursors, nil
+}
+
+func (storage *MemoryRepository) CountFeed(_ context.Context, id model.UserId, since model.PageToken, limit int) (int, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	feed := storage.feeds[id]
+	count := len(feed)
+
+	if since != model.EmptyPage {
+		token, err := parseCursor(since)
+		if err != nil {
+			return 0, err
+		}
+		count = sort.Search(len(feed), func(i int) bool {
+			return compareTokens(feed[i].Token, token) <= 0
+		})
+	}
+
+	return min(count, limit), nil
+}
+
+func (storage *MemoryRepository) GetFeedPosts(ctx context.Context, id model.UserId, page model.PageRequest) (model.FeedPage, error) {
+	return hydrateFeed(ctx, storage, id, page)
+}
+
+func (storage *MemoryRepository) AddPostToFeed(_ context.Context, post model.FeedMetadataDocument) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	feed := storage.feeds[post.UserId]
+	i := sort.Search(len(feed), func(i int) bool {
+		return compareTokens(feed[i].Token, post.Token) <= 0
+	})
+	if i < len(feed) && feed[i].Token == post.Token {
+		return nil
+	}
+	feed = append(feed, model.FeedMetadataDocument{})
+	copy(feed[i+1:], feed[i:])
+	feed[i] = post
+	storage.feeds[post.UserId] = feed
+
+	return nil
+}
+
+func (storage *MemoryRepository) RemovePostsFromFeed(_ context.Context, id model.UserId, ids []model.PostId) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	removed := make(map[model.PostId]bool, len(ids))
+	for _, postId := range ids {
+		removed[postId] = true
+	}
+
+	feed := storage.feeds[id][:0]
+	for _, item := range storage.feeds[id] {
+		if !removed[item.PostId] {
+			feed = append(feed, item)
+		}
+	}
+	storage.feeds[id] = feed
+
+	return nil
+}
+
+func (storage *MemoryRepository) HealthChecks() []HealthCheck {
+	return nil
+}
+
+func (storage *MemoryRepository) Close() error {
+	return nil
+}
+
+// pageIndexes returns indexes of the page items and one extra item in a list ordered by token descending,
+// in the order mongo would fetch them. The cursor does not have to belong to the list, like a range query in mongo
+func pageIndexes(page model.PageRequest, length int, tokenAt func(i int) primitive.ObjectID) ([]int, error) {
+	indexes := make([]int, 0, page.Size+1)
+	start := 0
+
+	if page.Cursor != model.EmptyPage {
+		token, err := parseCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		if newerPage(page) {
+			// newer items are at the head of the list, the nearest to the cursor go first
+			end := sort.Search(length, func(i int) bool {
+				return compareTokens(tokenAt(i), token) <= 0
+			})
+			for i := end - 1; i >= 0 && len(indexes) < page.Size+1; i-- {
+				indexes = append(indexes, i)
+			}
+			return indexes, nil
+		}
+
+		start = sort.Search(length, func(i int) bool {
+			return compareTokens(tokenAt(i), token) < 0
+		})
+	}
+
+	for i := start; i < length && len(indexes) < page.Size+1; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+func compareTokens(a, b primitive.ObjectID) int {
+	return bytes.Compare(a[:], b[:])
+}
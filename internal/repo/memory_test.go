@@ -0,0 +1,48 @@
+package repo_test
+
+import (
+	"context"
+	"microblog/internal/model"
+	"microblog/internal/repo"
+	"microblog/internal/repo/repotest"
+	"sync"
+	"testing"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repo.Repository {
+		return repo.NewMemoryRepository()
+	})
+}
+
+func TestMemoryRepositoryConcurrentWrites(t *testing.T) {
+	r := repo.NewMemoryRepository()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				post, err := r.CreatePost(context.Background(), "aa", model.Post{Text: "post"})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_ = r.AddPostToFeed(context.Background(), model.FeedMetadataDocument{UserId: "bb", PostId: post.Id, Token: post.Token})
+			}
+		}()
+	}
+	wg.Wait()
+
+	posts := repotest.CollectPosts(t, r, "aa", 7)
+	feed := repotest.CollectFeed(t, r, "bb", 7)
+	if len(posts) != 400 || len(feed) != 400 {
+		t.Fatalf("got %d posts and %d feed items, want 400 of each", len(posts), len(feed))
+	}
+	for i := 1; i < len(posts); i++ {
+		if posts[i-1].Token.Hex() <= posts[i].Token.Hex() {
+			t.Fatalf("posts %d and %d are out of order", i-1, i)
+		}
+	}
+}
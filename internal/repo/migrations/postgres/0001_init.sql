@@ -0,0 +1,33 @@
+-- Tokens are ObjectIDs, bytea compares them byte by byte like mongo does, so keyset pages follow the same order.
+-- Ids of posts are hex of their tokens and are not stored
+
+CREATE TABLE posts (
+    token            bytea PRIMARY KEY,
+    author_id        text        NOT NULL,
+    text             text        NOT NULL,
+    created_at       timestamptz NOT NULL,
+    last_modified_at timestamptz NOT NULL
+);
+
+CREATE INDEX posts_author_id_token_idx ON posts (author_id, token DESC);
+
+-- a post is added to a feed once, so retried fan-out does not duplicate it
+CREATE TABLE feeds (
+    user_id text  NOT NULL,
+    token   bytea NOT NULL,
+    post_id text  NOT NULL,
+    PRIMARY KEY (user_id, token)
+);
+
+CREATE INDEX feeds_user_id_post_id_idx ON feeds (user_id, post_id);
+
+-- position keeps lists of subscribers and subscriptions in the order of subscription
+CREATE TABLE subscriptions (
+    subscriber_id text   NOT NULL,
+    target_id     text   NOT NULL,
+    position      bigint GENERATED ALWAYS AS IDENTITY,
+    PRIMARY KEY (subscriber_id, target_id)
+);
+
+CREATE INDEX subscriptions_subscriber_id_position_idx ON subscriptions (subscriber_id, position);
+CREATE INDEX subscriptions_target_id_position_idx ON subscriptions (target_id, position);
@@ -0,0 +1,32 @@
+-- Tokens are ObjectIDs, blobs compare them byte by byte like mongo does, so keyset pages follow the same order.
+-- Ids of posts are hex of their tokens and are not stored, timestamps are kept as ISO strings of the api
+
+CREATE TABLE posts (
+    token            BLOB PRIMARY KEY,
+    author_id        TEXT NOT NULL,
+    text             TEXT NOT NULL,
+    created_at       TEXT NOT NULL,
+    last_modified_at TEXT NOT NULL
+) WITHOUT ROWID;
+
+CREATE INDEX posts_author_id_token_idx ON posts (author_id, token DESC);
+
+-- a post is added to a feed once, so retried fan-out does not duplicate it
+CREATE TABLE feeds (
+    user_id TEXT NOT NULL,
+    token   BLOB NOT NULL,
+    post_id TEXT NOT NULL,
+    PRIMARY KEY (user_id, token)
+) WITHOUT ROWID;
+
+CREATE INDEX feeds_user_id_post_id_idx ON feeds (user_id, post_id);
+
+-- position keeps lists of subscribers and subscriptions in the order of subscription
+CREATE TABLE subscriptions (
+    position      INTEGER PRIMARY KEY AUTOINCREMENT,
+    subscriber_id TEXT NOT NULL,
+    target_id     TEXT NOT NULL,
+    UNIQUE (subscriber_id, target_id)
+);
+
+CREATE INDEX subscriptions_target_id_position_idx ON subscriptions (target_id, position);
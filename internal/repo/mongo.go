@@ -7,11 +7,13 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"go.mongodb.org/mongo-driver/x/bsonx"
-	"log"
+	"log/slog"
+	"microblog/internal/config"
+	"microblog/internal/metrics"
 	"microblog/internal/model"
 	"microblog/internal/utils"
-	"os"
 	"time"
 )
 
@@ -22,41 +24,65 @@ import (
 var _ Repository = (*MongoDatabaseRepository)(nil)
 
 type MongoDatabaseRepository struct {
+	client    *mongo.Client
 	posts     *mongo.Collection
 	feeds     *mongo.Collection
 	following *mongo.Collection
 	followed  *mongo.Collection
+	timeout   time.Duration
 }
 
-func NewMongoDatabaseRepository() Repository {
-	dbUrl, ok := os.LookupEnv("MONGO_URL")
-	if !ok {
-		dbUrl = "mongodb://localhost:27017"
-	}
-	dbName, ok := os.LookupEnv("MONGO_DBNAME")
-	if !ok {
-		dbName = "system_design"
+func NewMongoDatabaseRepository(ctx context.Context, cfg config.Config) (Repository, error) {
+	client, err := mongo.Connect(ctx, options.Client().
+		ApplyURI(cfg.Mongo.URL).
+		SetConnectTimeout(cfg.Mongo.ConnectTimeout).
+		SetServerSelectionTimeout(cfg.Mongo.ConnectTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mongo client: %w", err)
 	}
-	ctx := context.Background()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(dbUrl))
+
+	err = utils.Retry(ctx, cfg.Retry, "mongo ping", func(ctx context.Context) error {
+		pingCtx, cancel := context.WithTimeout(ctx, cfg.Mongo.ConnectTimeout)
+		defer cancel()
+		return client.Ping(pingCtx, readpref.Primary())
+	})
 	if err != nil {
-		panic(err)
+		_ = client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to connect to mongo: %w", err)
 	}
 
-	posts := client.Database(dbName).Collection("posts")
-	ensureIndexesForPosts(ctx, posts)
-	feeds := client.Database(dbName).Collection("feeds")
-	ensureIndexesForFeed(ctx, feeds)
+	db := client.Database(cfg.Mongo.DBName)
+	storage := &MongoDatabaseRepository{
+		client:    client,
+		posts:     db.Collection("posts"),
+		feeds:     db.Collection("feeds"),
+		following: db.Collection("following"),
+		followed:  db.Collection("followed"),
+		timeout:   cfg.Mongo.ConnectTimeout,
+	}
 
-	following := client.Database(dbName).Collection("following")
-	ensureIndexesById(ctx, following)
-	followed := client.Database(dbName).Collection("followed")
-	ensureIndexesById(ctx, followed)
+	if err = storage.ensureIndexes(ctx, cfg.Mongo.IndexTimeout); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, err
+	}
 
-	return &MongoDatabaseRepository{posts: posts, feeds: feeds, followed: followed, following: following}
+	return storage, nil
 }
 
-func ensureIndexesForPosts(ctx context.Context, collection *mongo.Collection) {
+func (storage *MongoDatabaseRepository) ensureIndexes(ctx context.Context, timeout time.Duration) error {
+	if err := ensureIndexesForPosts(ctx, storage.posts, timeout); err != nil {
+		return err
+	}
+	if err := ensureIndexesForFeed(ctx, storage.feeds, timeout); err != nil {
+		return err
+	}
+	if err := ensureIndexesById(ctx, storage.following, timeout); err != nil {
+		return err
+	}
+	return ensureIndexesById(ctx, storage.followed, timeout)
+}
+
+func ensureIndexesForPosts(ctx context.Context, collection *mongo.Collection, timeout time.Duration) error {
 	indexModels := []mongo.IndexModel{
 		{
 			Keys: bsonx.Doc{
@@ -65,15 +91,16 @@ func ensureIndexesForPosts(ctx context.Context, collection *mongo.Collection) {
 			},
 		},
 	}
-	opts := options.CreateIndexes().SetMaxTime(10 * time.Second)
+	opts := options.CreateIndexes().SetMaxTime(timeout)
 
 	_, err := collection.Indexes().CreateMany(ctx, indexModels, opts)
 	if err != nil {
-		panic(fmt.Errorf("failed to ensure indexes %w", err))
+		return fmt.Errorf("failed to ensure indexes %w", err)
 	}
+	return nil
 }
 
-func ensureIndexesForFeed(ctx context.Context, collection *mongo.Collection) {
+func ensureIndexesForFeed(ctx context.Context, collection *mongo.Collection, timeout time.Duration) error {
 	indexModels := []mongo.IndexModel{
 		{
 			Keys: bsonx.Doc{
@@ -82,15 +109,16 @@ func ensureIndexesForFeed(ctx context.Context, collection *mongo.Collection) {
 			},
 		},
 	}
-	opts := options.CreateIndexes().SetMaxTime(10 * time.Second)
+	opts := options.CreateIndexes().SetMaxTime(timeout)
 
 	_, err := collection.Indexes().CreateMany(ctx, indexModels, opts)
 	if err != nil {
-		panic(fmt.Errorf("failed to ensure indexes %w", err))
+		return fmt.Errorf("failed to ensure indexes %w", err)
 	}
+	return nil
 }
 
-func ensureIndexesById(ctx context.Context, collection *mongo.Collection) {
+func ensureIndexesById(ctx context.Context, collection *mongo.Collection, timeout time.Duration) error {
 	indexModels := []mongo.IndexModel{
 		{
 			Keys: bsonx.Doc{
@@ -98,15 +126,33 @@ func ensureIndexesById(ctx context.Context, collection *mongo.Collection) {
 			},
 		},
 	}
-	opts := options.CreateIndexes().SetMaxTime(10 * time.Second)
+	opts := options.CreateIndexes().SetMaxTime(timeout)
 
 	_, err := collection.Indexes().CreateMany(ctx, indexModels, opts)
 	if err != nil {
-		panic(fmt.Errorf("failed to ensure indexes by id %w", err))
+		return fmt.Errorf("failed to ensure indexes by id %w", err)
 	}
+	return nil
+}
+
+func (storage *MongoDatabaseRepository) HealthChecks() []HealthCheck {
+	return []HealthCheck{{
+		Name: "mongo",
+		Ping: func(ctx context.Context) error {
+			return storage.client.Ping(ctx, readpref.Primary())
+		},
+	}}
+}
+
+func (storage *MongoDatabaseRepository) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), storage.timeout)
+	defer cancel()
+	return storage.client.Disconnect(ctx)
 }
 
 func (storage *MongoDatabaseRepository) CreatePost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error) {
+	defer metrics.ObserveMongoOperation("create_post", time.Now())
+
 	post.Token = primitive.NewObjectID()
 	post.Id = model.PostId(post.Token.Hex())
 	post.AuthorId = id
@@ -118,7 +164,7 @@ func (storage *MongoDatabaseRepository) CreatePost(ctx context.Context, id model
 	_, err := storage.posts.InsertOne(ctx, post)
 
 	if err != nil {
-		log.Printf(err.Error())
+		slog.ErrorContext(ctx, "Failed to insert post", slog.String("author_id", string(id)), slog.Any("error", err))
 		err = model.PostCreationFailed
 	}
 
@@ -126,22 +172,22 @@ func (storage *MongoDatabaseRepository) CreatePost(ctx context.Context, id model
 }
 
 func (storage *MongoDatabaseRepository) EditPost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error) {
+	defer metrics.ObserveMongoOperation("edit_post", time.Now())
+
 	var result model.Post
 
 	err := storage.posts.FindOneAndUpdate(ctx,
 		bson.M{"id": post.Id},
 		bson.D{
-			{"$set",
-				bson.D{
-					{"text", post.Text},
-					{"lastModifiedAt", utils.Now()},
-				}},
+			{Key: "$set", Value: bson.D{
+				{Key: "text", Value: post.Text},
+				{Key: "lastModifiedAt", Value: utils.Now()},
+			}},
 		},
 		options.FindOneAndUpdate().SetReturnDocument(options.After),
 	).Decode(&result)
 
 	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
-		log.Printf(err.Error())
 		err = model.PostNotFound
 	}
 
@@ -149,71 +195,70 @@ func (storage *MongoDatabaseRepository) EditPost(ctx context.Context, id model.U
 }
 
 func (storage *MongoDatabaseRepository) GetPostById(ctx context.Context, id model.PostId) (model.Post, error) {
+	defer metrics.ObserveMongoOperation("get_post_by_id", time.Now())
+
 	var result model.Post
 	err := storage.posts.FindOne(ctx, bson.M{"id": id}).Decode(&result)
 	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
-		log.Printf(err.Error())
 		err = model.PostNotFound
 	}
 	return result, err
 }
 
-func (storage *MongoDatabaseRepository) GetPosts(ctx context.Context, id model.UserId, page model.PageToken, size int) ([]model.Post, model.PageToken, error) {
-	var result []model.Post
-	newToken := model.EmptyPage
-
-	opts := options.Find().
-		SetSort(bson.D{{"authorId", 1}, {"_id", -1}}).
-		SetLimit(int64(size + 1))
+func (storage *MongoDatabaseRepository) GetPostsByIds(ctx context.Context, ids []model.PostId) ([]model.Post, error) {
+	defer metrics.ObserveMongoOperation("get_posts_by_ids", time.Now())
 
-	if page == model.EmptyPage {
-		cursor, err := storage.posts.Find(ctx, bson.D{{"authorId", id}}, opts)
-
-		if err != nil {
-			return result, newToken, err
+	tokens := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		// ids which are not tokens can't belong to existing posts
+		if token, err := primitive.ObjectIDFromHex(string(id)); err == nil {
+			tokens = append(tokens, token)
 		}
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
 
-		if err = cursor.All(ctx, &result); err != nil {
-			return result, newToken, err
-		}
-	} else {
-		token, err := primitive.ObjectIDFromHex(string(page))
-		if err != nil {
-			return result, model.EmptyPage, model.InvalidPageToken
-		}
+	cursor, err := storage.posts.Find(ctx, bson.M{"_id": bson.M{"$in": tokens}})
+	if err != nil {
+		return nil, err
+	}
 
-		// naive way to validate page token
-		var r model.Post
-		err = storage.posts.FindOne(ctx, bson.M{"_id": token}).Decode(&r)
-		if err != nil || r.AuthorId != id {
-			return result, model.EmptyPage, model.InvalidPageToken
-		}
+	var found []model.Post
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
 
-		cursor, err := storage.posts.Find(ctx,
-			bson.D{{"authorId", id}, {"_id", bson.M{"$lt": token}}}, opts)
+	return orderPosts(ids, found), nil
+}
 
-		if err != nil {
-			return result, newToken, err
-		}
+func (storage *MongoDatabaseRepository) GetPosts(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.Post, model.PageCursors, error) {
+	defer metrics.ObserveMongoOperation("get_posts", time.Now())
 
-		if err = cursor.All(ctx, &result); err != nil {
-			return result, newToken, err
-		}
+	filter, opts, err := pageQuery(bson.E{Key: "authorId", Value: id}, "_id", page)
+	if err != nil {
+		return nil, model.PageCursors{}, err
 	}
 
-	if len(result) > 1 && len(result) == size+1 {
-		newToken = model.PageToken(result[len(result)-2].Token.Hex())
-		result = result[0 : len(result)-1]
-	} else {
-		newToken = model.EmptyPage
+	var result []model.Post
+	cursor, err := storage.posts.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, model.PageCursors{}, err
 	}
 
-	return result, newToken, nil
+	if err = cursor.All(ctx, &result); err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	result, cursors := pageOf(result, page, func(post model.Post) primitive.ObjectID { return post.Token })
+	return result, cursors, nil
 }
 
 func (storage *MongoDatabaseRepository) Subscribe(ctx context.Context, subscriberId model.UserId, targetId model.UserId) error {
+	defer metrics.ObserveMongoOperation("subscribe", time.Now())
+
 	if subscriberId == targetId {
-		return fmt.Errorf("fromId == toId --> %s", subscriberId)
+		return model.SelfSubscription
 	}
 
 	opts := options.Update().SetUpsert(true)
@@ -250,6 +295,8 @@ func (storage *MongoDatabaseRepository) Subscribe(ctx context.Context, subscribe
 }
 
 func (storage *MongoDatabaseRepository) GetSubscriptions(ctx context.Context, id model.UserId) ([]model.UserId, error) {
+	defer metrics.ObserveMongoOperation("get_subscriptions", time.Now())
+
 	var result []model.UserId
 	var doc model.SubscriptionsDocument
 	err := storage.following.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
@@ -259,7 +306,6 @@ func (storage *MongoDatabaseRepository) GetSubscriptions(ctx context.Context, id
 	}
 
 	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
-		log.Printf(err.Error())
 		err = nil
 		result = []model.UserId{}
 	}
@@ -268,6 +314,8 @@ func (storage *MongoDatabaseRepository) GetSubscriptions(ctx context.Context, id
 }
 
 func (storage *MongoDatabaseRepository) GetSubscribers(ctx context.Context, id model.UserId) ([]model.UserId, error) {
+	defer metrics.ObserveMongoOperation("get_subscribers", time.Now())
+
 	var result []model.UserId
 	var doc model.SubscriptionsDocument
 	err := storage.followed.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
@@ -277,7 +325,6 @@ func (storage *MongoDatabaseRepository) GetSubscribers(ctx context.Context, id m
 	}
 
 	if err != nil && errors.Is(err, mongo.ErrNoDocuments) {
-		log.Printf(err.Error())
 		err = nil
 		result = []model.UserId{}
 	}
@@ -285,59 +332,87 @@ func (storage *MongoDatabaseRepository) GetSubscribers(ctx context.Context, id m
 	return result, err
 }
 
-func (storage *MongoDatabaseRepository) GetFeed(ctx context.Context, id model.UserId, page model.PageToken, size int) ([]model.FeedMetadataDocument, model.PageToken, error) {
+func (storage *MongoDatabaseRepository) GetFeed(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.FeedMetadataDocument, model.PageCursors, error) {
+	defer metrics.ObserveMongoOperation("get_feed", time.Now())
+
+	filter, opts, err := pageQuery(bson.E{Key: "userId", Value: id}, "token", page)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
 	var result []model.FeedMetadataDocument
-	newToken := model.EmptyPage
+	cursor, err := storage.feeds.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
 
-	opts := options.Find().
-		SetSort(bson.D{{"userId", 1}, {"token", -1}}).
-		SetLimit(int64(size + 1))
+	if err = cursor.All(ctx, &result); err != nil {
+		return nil, model.PageCursors{}, err
+	}
 
-	if page == model.EmptyPage {
-		cursor, err := storage.feeds.Find(ctx, bson.D{{"userId", id}}, opts)
-		if err != nil {
-			return result, newToken, err
-		}
+	result, cursors := pageOf(result, page, func(item model.FeedMetadataDocument) primitive.ObjectID { return item.Token })
+	return result, cursors, nil
+}
 
-		if err = cursor.All(ctx, &result); err != nil {
-			return result, newToken, err
-		}
-	} else {
-		token, err := primitive.ObjectIDFromHex(string(page))
+func (storage *MongoDatabaseRepository) CountFeed(ctx context.Context, id model.UserId, since model.PageToken, limit int) (int, error) {
+	defer metrics.ObserveMongoOperation("count_feed", time.Now())
+
+	filter := bson.D{{Key: "userId", Value: id}}
+	if since != model.EmptyPage {
+		token, err := parseCursor(since)
 		if err != nil {
-			return result, model.EmptyPage, model.InvalidPageToken
+			return 0, err
 		}
+		filter = append(filter, bson.E{Key: "token", Value: bson.M{"$gt": token}})
+	}
 
-		var r model.FeedMetadataDocument
-		err = storage.feeds.FindOne(ctx, bson.M{"token": token}).Decode(&r)
-		if err != nil || r.UserId != id {
-			return result, model.EmptyPage, model.InvalidPageToken
-		}
+	count, err := storage.feeds.CountDocuments(ctx, filter, options.Count().SetLimit(int64(limit)))
+	return int(count), err
+}
 
-		cursor, err := storage.feeds.Find(ctx,
-			bson.D{{"userId", id}, {"token", bson.M{"$lt": token}}}, opts)
+// pageQuery selects the page of the owner's list sorted by the token field, it is covered by (owner, token) index.
+// One extra item is fetched to know if there are more items
+func pageQuery(owner bson.E, tokenField string, page model.PageRequest) (bson.D, *options.FindOptions, error) {
+	filter := bson.D{owner}
+	order := -1
 
+	if page.Cursor != model.EmptyPage {
+		token, err := parseCursor(page.Cursor)
 		if err != nil {
-			return result, newToken, err
+			return nil, nil, err
 		}
 
-		if err = cursor.All(ctx, &result); err != nil {
-			return result, newToken, err
+		op := "$lt"
+		if newerPage(page) {
+			op, order = "$gt", 1
 		}
+		filter = append(filter, bson.E{Key: tokenField, Value: bson.M{op: token}})
 	}
 
-	if len(result) > 1 && len(result) == size+1 {
-		newToken = model.PageToken(result[len(result)-2].Token.Hex())
-		result = result[0 : len(result)-1]
-	} else {
-		newToken = model.EmptyPage
-	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: owner.Key, Value: 1}, {Key: tokenField, Value: order}}).
+		SetLimit(int64(page.Size + 1))
 
-	return result, newToken, nil
+	return filter, opts, nil
+}
+
+func (storage *MongoDatabaseRepository) GetFeedPosts(ctx context.Context, id model.UserId, page model.PageRequest) (model.FeedPage, error) {
+	return hydrateFeed(ctx, storage, id, page)
 }
 
 func (storage *MongoDatabaseRepository) AddPostToFeed(ctx context.Context, post model.FeedMetadataDocument) error {
-	_, err := storage.feeds.InsertOne(ctx, post)
+	defer metrics.ObserveMongoOperation("add_post_to_feed", time.Now())
+
+	// the item is upserted by its key, so retried fan-out doesn't add it twice
+	_, err := storage.feeds.UpdateOne(ctx, bson.M{"userId": post.UserId, "token": post.Token},
+		bson.M{"$setOnInsert": post}, options.Update().SetUpsert(true))
+
+	return err
+}
+
+func (storage *MongoDatabaseRepository) RemovePostsFromFeed(ctx context.Context, id model.UserId, ids []model.PostId) error {
+	defer metrics.ObserveMongoOperation("remove_posts_from_feed", time.Now())
 
+	_, err := storage.feeds.DeleteMany(ctx, bson.M{"userId": id, "postId": bson.M{"$in": ids}})
 	return err
 }
@@ -0,0 +1,44 @@
+package repo_test
+
+import (
+	"context"
+	"microblog/internal/config"
+	"microblog/internal/repo"
+	"microblog/internal/repo/repotest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var mongoDatabases atomic.Int64
+
+// TestMongoDatabaseRepository runs the contract against a real MongoDB, if TEST_MONGO_URL is set.
+// Every test case gets its own database which is dropped afterwards
+func TestMongoDatabaseRepository(t *testing.T) {
+	url := os.Getenv("TEST_MONGO_URL")
+	if url == "" {
+		t.Skip("TEST_MONGO_URL is not set")
+	}
+
+	repotest.Run(t, func(t *testing.T) repo.Repository {
+		cfg := config.Default()
+		cfg.Mongo.URL = url
+		cfg.Mongo.DBName = "microblog_test_" + strconv.FormatInt(time.Now().UnixNano(), 36) + "_" + strconv.FormatInt(mongoDatabases.Add(1), 10)
+		cfg.Retry.MaxAttempts = 1
+
+		r, err := repo.NewMongoDatabaseRepository(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if err := repo.DropMongoDatabase(context.Background(), r); err != nil {
+				t.Error(err)
+			}
+			_ = r.Close()
+		})
+
+		return r
+	})
+}
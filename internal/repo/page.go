@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"microblog/internal/model"
+	"slices"
+)
+
+// newerPage reports if the page goes towards the newest item, without a cursor it is the first page
+func newerPage(page model.PageRequest) bool {
+	return page.Direction == model.DirectionNewer && page.Cursor != model.EmptyPage
+}
+
+func parseCursor(cursor model.PageToken) (primitive.ObjectID, error) {
+	token, err := primitive.ObjectIDFromHex(string(cursor))
+	if err != nil {
+		return token, model.InvalidPageToken
+	}
+	return token, nil
+}
+
+// pageOf trims items fetched with one extra item and computes cursors of adjacent pages.
+// Items of older pages are fetched from the newest one, items of newer pages from the oldest one,
+// the result is always ordered from the newest item
+func pageOf[T any](items []T, page model.PageRequest, tokenOf func(item T) primitive.ObjectID) ([]T, model.PageCursors) {
+	cursors := model.PageCursors{Older: model.EmptyPage, Newer: page.Cursor}
+
+	if newerPage(page) {
+		if len(items) > page.Size {
+			items = items[:page.Size]
+		}
+		slices.Reverse(items)
+		if len(items) > 0 {
+			cursors.Older = model.PageToken(tokenOf(items[len(items)-1]).Hex())
+		}
+	} else if len(items) > page.Size {
+		items = items[:page.Size]
+		cursors.Older = model.PageToken(tokenOf(items[len(items)-1]).Hex())
+	}
+
+	if len(items) > 0 {
+		cursors.Newer = model.PageToken(tokenOf(items[0]).Hex())
+	}
+
+	return items, cursors
+}
@@ -0,0 +1,394 @@
+package repo
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io/fs"
+	"log/slog"
+	"microblog/internal/config"
+	"microblog/internal/metrics"
+	"microblog/internal/model"
+	"microblog/internal/utils"
+	"path"
+	"slices"
+	"time"
+)
+
+var _ Repository = (*PostgresRepository)(nil)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresMigrationLock is the key of the advisory lock held while migrations are applied,
+// so servers starting at the same time apply them once
+const postgresMigrationLock = 0x6d6963726f626c6f
+
+const postColumns = "token, author_id, text, created_at, last_modified_at"
+
+type PostgresRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresRepository(ctx context.Context, cfg config.Config) (Repository, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.Postgres.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse postgres url: %w", err)
+	}
+	poolConfig.ConnConfig.ConnectTimeout = cfg.Postgres.ConnectTimeout
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create postgres pool: %w", err)
+	}
+
+	err = utils.Retry(ctx, cfg.Retry, "postgres ping", func(ctx context.Context) error {
+		pingCtx, cancel := context.WithTimeout(ctx, cfg.Postgres.ConnectTimeout)
+		defer cancel()
+		return pool.Ping(pingCtx)
+	})
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+
+	storage := &PostgresRepository{pool: pool}
+
+	if err = storage.migrate(ctx, cfg.Postgres.MigrationTimeout); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+// migrate applies embedded migrations which were not applied yet in the order of their file names
+func (storage *PostgresRepository) migrate(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	files, err := fs.Glob(postgresMigrations, "migrations/postgres/*.sql")
+	if err != nil {
+		return err
+	}
+
+	tx, err := storage.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to migrate postgres: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", postgresMigrationLock); err != nil {
+		return fmt.Errorf("failed to lock postgres migrations: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    text PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to migrate postgres: %w", err)
+	}
+
+	rows, _ := tx.Query(ctx, "SELECT version FROM schema_migrations")
+	applied, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to migrate postgres: %w", err)
+	}
+
+	for _, file := range files {
+		version := path.Base(file)
+		if slices.Contains(applied, version) {
+			continue
+		}
+
+		migration, err := postgresMigrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		// without arguments the script is sent as a simple query, so it may have several statements
+		if _, err = tx.Exec(ctx, string(migration)); err != nil {
+			return fmt.Errorf("failed to apply postgres migration %s: %w", version, err)
+		}
+		if _, err = tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+			return fmt.Errorf("failed to apply postgres migration %s: %w", version, err)
+		}
+
+		slog.InfoContext(ctx, "Applied postgres migration", slog.String("version", version))
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (storage *PostgresRepository) HealthChecks() []HealthCheck {
+	return []HealthCheck{{
+		Name: "postgres",
+		Ping: func(ctx context.Context) error {
+			return storage.pool.Ping(ctx)
+		},
+	}}
+}
+
+func (storage *PostgresRepository) Close() error {
+	storage.pool.Close()
+	return nil
+}
+
+func (storage *PostgresRepository) CreatePost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error) {
+	defer metrics.ObservePostgresOperation("create_post", time.Now())
+
+	post.Token = primitive.NewObjectID()
+	post.Id = model.PostId(post.Token.Hex())
+	post.AuthorId = id
+
+	now := time.Now().UTC().Truncate(time.Second)
+	post.CreatedAt = isoTimestamp(now)
+	post.LastModifiedAt = post.CreatedAt
+
+	_, err := storage.pool.Exec(ctx, "INSERT INTO posts ("+postColumns+") VALUES ($1, $2, $3, $4, $4)",
+		post.Token[:], string(post.AuthorId), post.Text, now)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to insert post", slog.String("author_id", string(id)), slog.Any("error", err))
+		err = model.PostCreationFailed
+	}
+
+	return post, err
+}
+
+func (storage *PostgresRepository) EditPost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error) {
+	defer metrics.ObservePostgresOperation("edit_post", time.Now())
+
+	token, err := primitive.ObjectIDFromHex(string(post.Id))
+	if err != nil {
+		return model.Post{}, model.PostNotFound
+	}
+
+	rows, _ := storage.pool.Query(ctx, "UPDATE posts SET text = $2, last_modified_at = $3 WHERE token = $1 RETURNING "+postColumns,
+		token[:], post.Text, time.Now().UTC().Truncate(time.Second))
+	result, err := pgx.CollectOneRow(rows, scanPost)
+
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		err = model.PostNotFound
+	}
+
+	return result, err
+}
+
+func (storage *PostgresRepository) GetPostById(ctx context.Context, id model.PostId) (model.Post, error) {
+	defer metrics.ObservePostgresOperation("get_post_by_id", time.Now())
+
+	// ids which are not tokens can't belong to existing posts
+	token, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return model.Post{}, model.PostNotFound
+	}
+
+	rows, _ := storage.pool.Query(ctx, "SELECT "+postColumns+" FROM posts WHERE token = $1", token[:])
+	result, err := pgx.CollectOneRow(rows, scanPost)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		err = model.PostNotFound
+	}
+	return result, err
+}
+
+func (storage *PostgresRepository) GetPostsByIds(ctx context.Context, ids []model.PostId) ([]model.Post, error) {
+	defer metrics.ObservePostgresOperation("get_posts_by_ids", time.Now())
+
+	tokens := make([][]byte, 0, len(ids))
+	for _, id := range ids {
+		// ids which are not tokens can't belong to existing posts
+		if token, err := primitive.ObjectIDFromHex(string(id)); err == nil {
+			tokens = append(tokens, token[:])
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	rows, _ := storage.pool.Query(ctx, "SELECT "+postColumns+" FROM posts WHERE token = ANY($1)", tokens)
+	found, err := pgx.CollectRows(rows, scanPost)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderPosts(ids, found), nil
+}
+
+func (storage *PostgresRepository) GetPosts(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.Post, model.PageCursors, error) {
+	defer metrics.ObservePostgresOperation("get_posts", time.Now())
+
+	query, args, err := keysetQuery("SELECT "+postColumns+" FROM posts WHERE author_id = $1", string(id), page)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	rows, _ := storage.pool.Query(ctx, query, args...)
+	result, err := pgx.CollectRows(rows, scanPost)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	result, cursors := pageOf(result, page, func(post model.Post) primitive.ObjectID { return post.Token })
+	return result, cursors, nil
+}
+
+func (storage *PostgresRepository) Subscribe(ctx context.Context, subscriberId model.UserId, targetId model.UserId) error {
+	defer metrics.ObservePostgresOperation("subscribe", time.Now())
+
+	if subscriberId == targetId {
+		return model.SelfSubscription
+	}
+
+	result, err := storage.pool.Exec(ctx,
+		"INSERT INTO subscriptions (subscriber_id, target_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		string(subscriberId), string(targetId))
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return model.AlreadySubscribed
+	}
+
+	return nil
+}
+
+func (storage *PostgresRepository) GetSubscriptions(ctx context.Context, id model.UserId) ([]model.UserId, error) {
+	defer metrics.ObservePostgresOperation("get_subscriptions", time.Now())
+
+	rows, _ := storage.pool.Query(ctx, "SELECT target_id FROM subscriptions WHERE subscriber_id = $1 ORDER BY position", string(id))
+	return pgx.CollectRows(rows, pgx.RowTo[model.UserId])
+}
+
+func (storage *PostgresRepository) GetSubscribers(ctx context.Context, id model.UserId) ([]model.UserId, error) {
+	defer metrics.ObservePostgresOperation("get_subscribers", time.Now())
+
+	rows, _ := storage.pool.Query(ctx, "SELECT subscriber_id FROM subscriptions WHERE target_id = $1 ORDER BY position", string(id))
+	return pgx.CollectRows(rows, pgx.RowTo[model.UserId])
+}
+
+func (storage *PostgresRepository) GetFeed(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.FeedMetadataDocument, model.PageCursors, error) {
+	defer metrics.ObservePostgresOperation("get_feed", time.Now())
+
+	query, args, err := keysetQuery("SELECT user_id, token, post_id FROM feeds WHERE user_id = $1", string(id), page)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	rows, _ := storage.pool.Query(ctx, query, args...)
+	result, err := pgx.CollectRows(rows, scanFeedItem)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	result, cursors := pageOf(result, page, func(item model.FeedMetadataDocument) primitive.ObjectID { return item.Token })
+	return result, cursors, nil
+}
+
+func (storage *PostgresRepository) CountFeed(ctx context.Context, id model.UserId, since model.PageToken, limit int) (int, error) {
+	defer metrics.ObservePostgresOperation("count_feed", time.Now())
+
+	query := "SELECT count(*) FROM (SELECT 1 FROM feeds WHERE user_id = $1 LIMIT $2) AS unread"
+	args := []any{string(id), limit}
+	if since != model.EmptyPage {
+		token, err := parseCursor(since)
+		if err != nil {
+			return 0, err
+		}
+		query = "SELECT count(*) FROM (SELECT 1 FROM feeds WHERE user_id = $1 AND token > $3 LIMIT $2) AS unread"
+		args = append(args, token[:])
+	}
+
+	var count int
+	err := storage.pool.QueryRow(ctx, query, args...).Scan(&count)
+	return count, err
+}
+
+func (storage *PostgresRepository) GetFeedPosts(ctx context.Context, id model.UserId, page model.PageRequest) (model.FeedPage, error) {
+	return hydrateFeed(ctx, storage, id, page)
+}
+
+func (storage *PostgresRepository) AddPostToFeed(ctx context.Context, post model.FeedMetadataDocument) error {
+	defer metrics.ObservePostgresOperation("add_post_to_feed", time.Now())
+
+	_, err := storage.pool.Exec(ctx, "INSERT INTO feeds (user_id, token, post_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		string(post.UserId), post.Token[:], string(post.PostId))
+
+	return err
+}
+
+func (storage *PostgresRepository) RemovePostsFromFeed(ctx context.Context, id model.UserId, ids []model.PostId) error {
+	defer metrics.ObservePostgresOperation("remove_posts_from_feed", time.Now())
+
+	postIds := make([]string, len(ids))
+	for i, postId := range ids {
+		postIds[i] = string(postId)
+	}
+
+	_, err := storage.pool.Exec(ctx, "DELETE FROM feeds WHERE user_id = $1 AND post_id = ANY($2)", string(id), postIds)
+	return err
+}
+
+// keysetQuery selects the page of the owner's list sorted by token, it is covered by (owner, token) index.
+// The query must filter the owner by $1. One extra item is fetched to know if there are more items
+func keysetQuery(query string, owner string, page model.PageRequest) (string, []any, error) {
+	args := []any{owner}
+	order := "DESC"
+
+	if page.Cursor != model.EmptyPage {
+		token, err := parseCursor(page.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+
+		op := "<"
+		if newerPage(page) {
+			op, order = ">", "ASC"
+		}
+		args = append(args, token[:])
+		query += fmt.Sprintf(" AND token %s $%d", op, len(args))
+	}
+
+	args = append(args, page.Size+1)
+	query += fmt.Sprintf(" ORDER BY token %s LIMIT $%d", order, len(args))
+
+	return query, args, nil
+}
+
+func scanPost(row pgx.CollectableRow) (model.Post, error) {
+	var post model.Post
+	var token []byte
+	var createdAt, lastModifiedAt time.Time
+
+	if err := row.Scan(&token, &post.AuthorId, &post.Text, &createdAt, &lastModifiedAt); err != nil {
+		return model.Post{}, err
+	}
+
+	copy(post.Token[:], token)
+	post.Id = model.PostId(post.Token.Hex())
+	post.CreatedAt = isoTimestamp(createdAt)
+	post.LastModifiedAt = isoTimestamp(lastModifiedAt)
+	return post, nil
+}
+
+func scanFeedItem(row pgx.CollectableRow) (model.FeedMetadataDocument, error) {
+	var item model.FeedMetadataDocument
+	var token []byte
+
+	if err := row.Scan(&item.UserId, &token, &item.PostId); err != nil {
+		return model.FeedMetadataDocument{}, err
+	}
+
+	copy(item.Token[:], token)
+	return item, nil
+}
+
+// isoTimestamp formats timestamps like utils.Now
+func isoTimestamp(t time.Time) model.ISOTimestamp {
+	return model.ISOTimestamp(t.UTC().Format(time.RFC3339))
+}
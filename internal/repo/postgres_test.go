@@ -0,0 +1,66 @@
+package repo_test
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"microblog/internal/config"
+	"microblog/internal/repo"
+	"microblog/internal/repo/repotest"
+	"net/url"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var postgresSchemas atomic.Int64
+
+// TestPostgresRepository runs the contract against a real PostgreSQL, if TEST_POSTGRES_URL is set.
+// Every test case gets its own schema which is dropped afterwards
+func TestPostgresRepository(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_URL")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+
+	repotest.Run(t, func(t *testing.T) repo.Repository {
+		ctx := context.Background()
+		schema := "microblog_test_" + strconv.FormatInt(time.Now().UnixNano(), 36) + "_" + strconv.FormatInt(postgresSchemas.Add(1), 10)
+
+		admin, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if _, err := admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+				t.Error(err)
+			}
+			_ = admin.Close(ctx)
+		})
+
+		// unknown parameters of the url are sent as runtime parameters of connections
+		u, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+
+		cfg := config.Default()
+		cfg.Postgres.URL = u.String()
+		cfg.Retry.MaxAttempts = 1
+
+		r, err := repo.NewPostgresRepository(ctx, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = r.Close() })
+
+		return r
+	})
+}
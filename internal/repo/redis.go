@@ -2,42 +2,132 @@ package repo
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
-	"log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/sync/singleflight"
+	"log/slog"
+	"microblog/internal/breaker"
+	"microblog/internal/codec"
+	"microblog/internal/config"
+	"microblog/internal/metrics"
 	"microblog/internal/model"
 	"microblog/internal/utils"
-	"os"
+	"slices"
+	"strconv"
 	"time"
 )
 
 var _ Repository = (*RedisRepository)(nil)
 
+// missingRecord is cached for posts which don't exist, so lookups of unknown ids don't reach the persistent repository
+const missingRecord = ""
+
+// RedisRepository caches the persistent repository. When redis fails, the breaker opens and reads bypass the cache,
+// invalidations which failed meanwhile are replayed before the cache is read again
 type RedisRepository struct {
 	client         *redis.Client
 	persistentRepo Repository
+	ttl            config.CacheConfig
+	// group coalesces concurrent loads of the same key
+	group         singleflight.Group
+	breaker       *breaker.Breaker
+	invalidations *invalidationQueue
+	format        codec.Format
+	// stop ends the reconciliation of cached user lists
+	stop context.CancelFunc
 }
 
-func NewRedisRepository(repo Repository) Repository {
-	url, ok := os.LookupEnv("REDIS_URL")
+func NewRedisRepository(ctx context.Context, cfg config.Config, repo Repository) (Repository, error) {
+	format, err := codec.NewFormat(cfg.Cache.Codec, cfg.Cache.PageCompression)
+	if err != nil {
+		return nil, err
+	}
 
-	if !ok {
-		url = "127.0.0.1:6379"
+	client := redis.NewClient(&redis.Options{
+		Addr:         cfg.Redis.Addr,
+		DialTimeout:  cfg.Redis.ConnectTimeout,
+		ReadTimeout:  cfg.Redis.OperationTimeout,
+		WriteTimeout: cfg.Redis.OperationTimeout,
+	})
+
+	err = utils.Retry(ctx, cfg.Retry, "redis ping", func(ctx context.Context) error {
+		pingCtx, cancel := context.WithTimeout(ctx, cfg.Redis.ConnectTimeout)
+		defer cancel()
+		return client.Ping(pingCtx).Err()
+	})
+	if err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
 	}
 
-	return &RedisRepository{
-		client:         redis.NewClient(&redis.Options{Addr: url}),
+	cb := breaker.New(cfg.Redis.BreakerThreshold, cfg.Redis.BreakerCooldown, func(open bool) {
+		metrics.SetCacheBreakerOpen(open)
+		if open {
+			slog.Warn("Redis is unavailable, reads bypass the cache")
+		} else {
+			slog.Info("Redis is available again")
+		}
+	})
+	client.AddHook(breaker.RedisHook{Breaker: cb})
+
+	reconcileCtx, stop := context.WithCancel(context.Background())
+	cache := &RedisRepository{
+		client:         client,
 		persistentRepo: repo,
+		ttl:            cfg.Cache,
+		breaker:        cb,
+		invalidations:  newInvalidationQueue(cfg.Redis.InvalidationQueueSize),
+		format:         format,
+		stop:           stop,
 	}
+
+	// lists are reconciled by workers only, servers of the same redis don't have to repeat it
+	if cfg.Mode != config.ModeServer && cfg.Cache.ReconcileInterval > 0 {
+		go cache.reconcile(reconcileCtx, cfg.Cache.ReconcileInterval)
+	}
+
+	return cache, nil
+}
+
+func (cache *RedisRepository) HealthChecks() []HealthCheck {
+	// the service works without the cache, so it's degraded rather than down
+	check := HealthCheck{
+		Name:     "redis",
+		Optional: true,
+		Ping: func(ctx context.Context) error {
+			return cache.client.Ping(ctx).Err()
+		},
+	}
+
+	return append([]HealthCheck{check}, cache.persistentRepo.HealthChecks()...)
+}
+
+// Close releases redis connections and closes the underlying persistent repository
+func (cache *RedisRepository) Close() error {
+	cache.stop()
+	err := cache.client.Close()
+	if persistentErr := cache.persistentRepo.Close(); err == nil {
+		err = persistentErr
+	}
+	return err
 }
 
 func (cache *RedisRepository) CreatePost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error) {
 	result, err := cache.persistentRepo.CreatePost(ctx, id, post)
 	if err == nil {
-		serialized, _ := json.Marshal(result)
-		cache.client.Set(ctx, utils.CreateRedisKeyForPost(result.Id), serialized, time.Hour)
-		cache.client.Del(ctx, utils.CreateRedisKeyForPostPage(result.AuthorId)) // invalidate post page cache
+		key := utils.CreateRedisKeyForPost(result.Id)
+		if serialized, ok := cache.encode(ctx, key, result, false); ok {
+			cache.client.Set(ctx, key, serialized, cache.jitter(cache.ttl.PostTTL))
+		}
+
+		timeline := utils.CreateRedisKeyForPostTimeline(result.AuthorId)
+		if cache.addToTimeline(ctx, timeline, result.Token) != nil {
+			cache.invalidations.add(timeline)
+		}
+		// pages beyond the timeline hold only ids, so edits don't invalidate them
+		cache.bumpGeneration(ctx, utils.CreateRedisKeyForPostsGeneration(result.AuthorId))
 	}
 
 	return result, err
@@ -46,181 +136,444 @@ func (cache *RedisRepository) CreatePost(ctx context.Context, id model.UserId, p
 func (cache *RedisRepository) EditPost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error) {
 	result, err := cache.persistentRepo.EditPost(ctx, id, post)
 	if err == nil {
-		serialized, _ := json.Marshal(result)
-		cache.client.Set(ctx, utils.CreateRedisKeyForPost(result.Id), serialized, time.Hour)
+		key := utils.CreateRedisKeyForPost(result.Id)
+		serialized, ok := cache.encode(ctx, key, result, false)
+		switch {
+		case !ok:
+			cache.invalidate(ctx, key)
+		case cache.client.Set(ctx, key, serialized, cache.jitter(cache.ttl.PostTTL)).Err() != nil:
+			cache.invalidations.add(key)
+		}
 	}
 
 	return result, err
 }
 
 func (cache *RedisRepository) GetPostById(ctx context.Context, id model.PostId) (model.Post, error) {
-	key := utils.CreateRedisKeyForPost(id)
-	result := cache.client.Get(ctx, key)
+	post, err := readThrough(ctx, cache, utils.CreateRedisKeyForPost(id), cache.ttl.PostTTL, model.PostNotFound, false,
+		func(ctx context.Context) (model.Post, error) {
+			return cache.persistentRepo.GetPostById(ctx, id)
+		})
+	if err != nil {
+		return model.Post{}, err
+	}
 
-	switch serialized, err := result.Result(); {
-	case err == redis.Nil:
-		// continue execution
-	case err != nil:
-		return model.Post{}, fmt.Errorf("failed to get value from redis due to error %s", err)
-	default:
-		log.Printf("Successfully obtained post from cache for key %s", key)
+	utils.RestorePostToken(&post)
+	return post, nil
+}
+
+// GetPostsByIds reads cached posts with a single MGET, misses are fetched from the persistent repository at once
+func (cache *RedisRepository) GetPostsByIds(ctx context.Context, ids []model.PostId) ([]model.Post, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = utils.CreateRedisKeyForPost(id)
+	}
+
+	if !cache.available(ctx, keys[0]) {
+		return cache.persistentRepo.GetPostsByIds(ctx, ids)
+	}
+
+	values, err := cache.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		metrics.ObserveCacheBypass(keys[0])
+		return cache.persistentRepo.GetPostsByIds(ctx, ids)
+	}
+
+	found := make([]model.Post, 0, len(ids))
+	var missing []model.PostId
+	for i, value := range values {
 		var post model.Post
-		err = json.Unmarshal([]byte(serialized), &post)
-		return post, err
+		serialized, ok := value.(string)
+		switch {
+		case ok && serialized == missingRecord:
+			metrics.ObserveCacheLookup(keys[i], true)
+		case ok && cache.decode(ctx, keys[i], serialized, &post):
+			metrics.ObserveCacheLookup(keys[i], true)
+			utils.RestorePostToken(&post)
+			found = append(found, post)
+		default:
+			metrics.ObserveCacheLookup(keys[i], false)
+			missing = append(missing, ids[i])
+		}
 	}
 
-	post, err := cache.persistentRepo.GetPostById(ctx, id)
-	if err == nil {
-		serialized, _ := json.Marshal(post)
-		cache.client.Set(ctx, utils.CreateRedisKeyForPost(post.Id), serialized, time.Hour)
+	if len(missing) == 0 {
+		return orderPosts(ids, found), nil
 	}
-	return post, err
-}
 
-func (cache *RedisRepository) GetPosts(ctx context.Context, id model.UserId, page model.PageToken, size int) ([]model.Post, model.PageToken, error) {
-	// cache only first page for each user
-	if page != model.EmptyPage {
-		return cache.persistentRepo.GetPosts(ctx, id, page, size)
+	fetched, err := cache.persistentRepo.GetPostsByIds(ctx, missing)
+	if err != nil {
+		return nil, err
 	}
 
-	key := utils.CreateRedisKeyForPostPage(id)
-	result := cache.client.Get(ctx, key)
+	pipe := cache.client.Pipeline()
+	for _, post := range fetched {
+		key := utils.CreateRedisKeyForPost(post.Id)
+		if serialized, ok := cache.encode(ctx, key, post, false); ok {
+			pipe.Set(ctx, key, serialized, cache.jitter(cache.ttl.PostTTL))
+		}
+	}
+	for _, id := range missing {
+		if !slices.ContainsFunc(fetched, func(post model.Post) bool { return post.Id == id }) {
+			pipe.Set(ctx, utils.CreateRedisKeyForPost(id), missingRecord, cache.ttl.NegativeTTL)
+		}
+	}
+	_, _ = pipe.Exec(ctx)
 
-	switch serialized, err := result.Result(); {
-	case err == redis.Nil:
-		// continue execution
-	case err != nil:
-		return []model.Post{}, model.EmptyPage, fmt.Errorf("failed to get value from redis due to error %s", err)
-	default:
-		log.Printf("Successfully obtained first page from cache for key %s", key)
-		var record model.PostPageCacheRecord
-		_ = json.Unmarshal([]byte(serialized), &record)
+	return orderPosts(ids, append(found, fetched...)), nil
+}
 
-		if size == len(record.Posts) {
-			return record.Posts, record.Page, nil
+// GetPosts serves pages from the post timeline of the user, posts are resolved with GetPostsByIds
+func (cache *RedisRepository) GetPosts(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.Post, model.PageCursors, error) {
+	key := utils.CreateRedisKeyForPostTimeline(id)
+	tokens, cursors, ok, err := cache.timelinePage(ctx, key, page, func(ctx context.Context, page model.PageRequest) ([]primitive.ObjectID, error) {
+		posts, _, err := cache.persistentRepo.GetPosts(ctx, id, page)
+		tokens := make([]primitive.ObjectID, len(posts))
+		for i, post := range posts {
+			tokens[i] = post.Token
 		}
-		// continue execution
+		return tokens, err
+	})
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+	if !ok {
+		return cache.getPostsBeyondTimeline(ctx, id, page)
+	}
+
+	ids := make([]model.PostId, len(tokens))
+	for i, token := range tokens {
+		ids[i] = model.PostId(token.Hex())
 	}
 
-	posts, newPage, err := cache.persistentRepo.GetPosts(ctx, id, page, size)
+	posts, err := cache.GetPostsByIds(ctx, ids)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+	return posts, cursors, nil
+}
 
-	if err == nil {
-		record := model.PostPageCacheRecord{Posts: posts, Page: newPage}
-		serialized, _ := json.Marshal(record)
-		cache.client.Set(ctx, key, serialized, time.Hour)
+// getPostsBeyondTimeline caches ids of pages which the timeline doesn't cover under the generation of the list,
+// so deep scrolling doesn't reach the persistent repository on every request
+func (cache *RedisRepository) getPostsBeyondTimeline(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.Post, model.PageCursors, error) {
+	keyOf := func(generation string) string {
+		return utils.CreateRedisKeyForPostPage(id, generation, page)
 	}
 
-	return posts, newPage, err
+	record, err := readThroughPage(ctx, cache, utils.CreateRedisKeyForPostsGeneration(id), keyOf, cache.ttl.PageTTL,
+		func(ctx context.Context) (model.TimelinePage, error) {
+			posts, cursors, err := cache.persistentRepo.GetPosts(ctx, id, page)
+			ids := make([]model.PostId, len(posts))
+			for i, post := range posts {
+				ids[i] = post.Id
+			}
+			return model.TimelinePage{Ids: ids, Cursors: cursors}, err
+		})
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	posts, err := cache.GetPostsByIds(ctx, record.Ids)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+	return posts, record.Cursors, nil
 }
 
+// Subscribe writes through to the cached user lists, so fan-out never misses a new subscriber
 func (cache *RedisRepository) Subscribe(ctx context.Context, from model.UserId, to model.UserId) error {
 	err := cache.persistentRepo.Subscribe(ctx, from, to)
 
 	if err == nil {
-		keyFrom := utils.CreateRedisKeyForSubscriptions(from)
-		keyTo := utils.CreateRedisKeyForSubscribers(to)
-		cache.client.Del(ctx, keyFrom)
-		cache.client.Del(ctx, keyTo)
+		cache.addToUserList(ctx, utils.CreateRedisKeyForSubscriptions(from), to)
+		cache.addToUserList(ctx, utils.CreateRedisKeyForSubscribers(to), from)
 	}
 
 	return err
 }
 
 func (cache *RedisRepository) GetSubscriptions(ctx context.Context, id model.UserId) ([]model.UserId, error) {
-	key := utils.CreateRedisKeyForSubscriptions(id)
-	result := cache.client.Get(ctx, key)
+	return cache.userList(ctx, utils.CreateRedisKeyForSubscriptions(id), func(ctx context.Context) ([]model.UserId, error) {
+		return cache.persistentRepo.GetSubscriptions(ctx, id)
+	})
+}
 
-	switch serialized, err := result.Result(); {
-	case err == redis.Nil:
-		// continue execution
-	case err != nil:
-		return []model.UserId{}, fmt.Errorf("failed to get value from redis due to error %s", err)
-	default:
-		log.Printf("Successfully obtained ids from cache for key %s", key)
-		var ids []model.UserId
-		err = json.Unmarshal([]byte(serialized), &ids)
-		return ids, err
+func (cache *RedisRepository) GetSubscribers(ctx context.Context, id model.UserId) ([]model.UserId, error) {
+	return cache.userList(ctx, utils.CreateRedisKeyForSubscribers(id), func(ctx context.Context) ([]model.UserId, error) {
+		return cache.persistentRepo.GetSubscribers(ctx, id)
+	})
+}
+
+// GetFeed serves pages from the feed timeline of the user
+func (cache *RedisRepository) GetFeed(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.FeedMetadataDocument, model.PageCursors, error) {
+	key := utils.CreateRedisKeyForFeedTimeline(id)
+	tokens, cursors, ok, err := cache.timelinePage(ctx, key, page, func(ctx context.Context, page model.PageRequest) ([]primitive.ObjectID, error) {
+		feed, _, err := cache.persistentRepo.GetFeed(ctx, id, page)
+		tokens := make([]primitive.ObjectID, len(feed))
+		for i, item := range feed {
+			tokens[i] = item.Token
+		}
+		return tokens, err
+	})
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+	if !ok {
+		return cache.getFeedBeyondTimeline(ctx, id, page)
 	}
 
-	ids, err := cache.persistentRepo.GetSubscriptions(ctx, id)
+	feed := make([]model.FeedMetadataDocument, len(tokens))
+	for i, token := range tokens {
+		feed[i] = model.FeedMetadataDocument{UserId: id, PostId: model.PostId(token.Hex()), Token: token}
+	}
+	return feed, cursors, nil
+}
 
-	if err == nil {
-		serialized, _ := json.Marshal(ids)
-		cache.client.Set(ctx, key, serialized, time.Hour)
+// getFeedBeyondTimeline caches ids of pages which the timeline doesn't cover under the feed generation
+func (cache *RedisRepository) getFeedBeyondTimeline(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.FeedMetadataDocument, model.PageCursors, error) {
+	keyOf := func(generation string) string {
+		return utils.CreateRedisKeyForFeedPage(id, generation, page)
 	}
 
-	return ids, err
+	record, err := readThroughPage(ctx, cache, utils.CreateRedisKeyForFeedGeneration(id), keyOf, cache.ttl.PageTTL,
+		func(ctx context.Context) (model.TimelinePage, error) {
+			feed, cursors, err := cache.persistentRepo.GetFeed(ctx, id, page)
+			ids := make([]model.PostId, len(feed))
+			for i, item := range feed {
+				ids[i] = item.PostId
+			}
+			return model.TimelinePage{Ids: ids, Cursors: cursors}, err
+		})
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	feed := make([]model.FeedMetadataDocument, len(record.Ids))
+	for i, postId := range record.Ids {
+		token, err := primitive.ObjectIDFromHex(string(postId))
+		if err != nil {
+			return nil, model.PageCursors{}, fmt.Errorf("unexpected id %q of a cached feed page", postId)
+		}
+		feed[i] = model.FeedMetadataDocument{UserId: id, PostId: postId, Token: token}
+	}
+	return feed, record.Cursors, nil
 }
 
-func (cache *RedisRepository) GetSubscribers(ctx context.Context, id model.UserId) ([]model.UserId, error) {
-	key := utils.CreateRedisKeyForSubscribers(id)
-	result := cache.client.Get(ctx, key)
+func (cache *RedisRepository) CountFeed(ctx context.Context, id model.UserId, since model.PageToken, limit int) (int, error) {
+	return cache.persistentRepo.CountFeed(ctx, id, since, limit)
+}
 
-	switch serialized, err := result.Result(); {
-	case err == redis.Nil:
-		// continue execution
-	case err != nil:
-		return []model.UserId{}, fmt.Errorf("failed to get value from redis due to error %s", err)
-	default:
-		log.Printf("Successfully obtained ids from cache for key %s", key)
-		var ids []model.UserId
-		err = json.Unmarshal([]byte(serialized), &ids)
-		return ids, err
+// GetFeedPosts caches hydrated pages if it's enabled. Edits of posts do not invalidate them,
+// so they appear on cached pages within HydratedFeedTTL
+func (cache *RedisRepository) GetFeedPosts(ctx context.Context, id model.UserId, page model.PageRequest) (model.FeedPage, error) {
+	if cache.ttl.HydratedFeedTTL <= 0 {
+		return hydrateFeed(ctx, cache, id, page)
+	}
+
+	keyOf := func(generation string) string {
+		return utils.CreateRedisKeyForHydratedFeedPage(id, generation, page)
+	}
+
+	// dangling items are cached too, the cleanup bumps the generation once they are removed
+	feed, err := readThroughPage(ctx, cache, utils.CreateRedisKeyForFeedGeneration(id), keyOf, cache.ttl.HydratedFeedTTL,
+		func(ctx context.Context) (model.FeedPage, error) {
+			return hydrateFeed(ctx, cache, id, page)
+		})
+	if err != nil {
+		return model.FeedPage{}, err
 	}
 
-	ids, err := cache.persistentRepo.GetSubscribers(ctx, id)
+	// a loaded page is shared by concurrent misses, so tokens are restored in a copy
+	feed.Posts = slices.Clone(feed.Posts)
+	for i := range feed.Posts {
+		utils.RestorePostToken(&feed.Posts[i])
+	}
+	return feed, nil
+}
+
+func (cache *RedisRepository) AddPostToFeed(ctx context.Context, post model.FeedMetadataDocument) error {
+	err := cache.persistentRepo.AddPostToFeed(ctx, post)
 
 	if err == nil {
-		serialized, _ := json.Marshal(ids)
-		cache.client.Set(ctx, key, serialized, time.Hour)
+		key := utils.CreateRedisKeyForFeedTimeline(post.UserId)
+		if cache.addToTimeline(ctx, key, post.Token) != nil {
+			cache.invalidations.add(key)
+		}
+		cache.bumpGeneration(ctx, utils.CreateRedisKeyForFeedGeneration(post.UserId))
 	}
 
-	return ids, err
+	return err
 }
 
-func (cache *RedisRepository) GetFeed(ctx context.Context, id model.UserId, page model.PageToken, size int) ([]model.FeedMetadataDocument, model.PageToken, error) {
-	// we cache only first page for each user
-	if page != model.EmptyPage {
-		return cache.persistentRepo.GetFeed(ctx, id, page, size)
+func (cache *RedisRepository) RemovePostsFromFeed(ctx context.Context, id model.UserId, ids []model.PostId) error {
+	err := cache.persistentRepo.RemovePostsFromFeed(ctx, id, ids)
+
+	if err == nil {
+		// a trimmed timeline would look complete after removal, so it's warmed up again
+		cache.invalidate(ctx, utils.CreateRedisKeyForFeedTimeline(id))
+		cache.bumpGeneration(ctx, utils.CreateRedisKeyForFeedGeneration(id))
+	}
+
+	return err
+}
+
+// generation returns the current generation of a cached list. A missing generation starts from the current time,
+// so it doesn't return to the values of an evicted one and pages cached under them are never served
+func (cache *RedisRepository) generation(ctx context.Context, key string) (string, error) {
+	generation, err := cache.client.Get(ctx, key).Result()
+	if err != redis.Nil {
+		return generation, err
 	}
 
-	key := utils.CreateRedisKeyForFeedPage(id)
-	result := cache.client.Get(ctx, key)
+	initial := strconv.FormatInt(time.Now().UnixNano(), 10)
+	created, err := cache.client.SetNX(ctx, key, initial, 0).Result()
+	if err != nil || created {
+		return initial, err
+	}
+	return cache.client.Get(ctx, key).Result()
+}
 
-	switch serialized, err := result.Result(); {
+// bumpGeneration invalidates all cached pages of a list at once, they are left to expire.
+// If it fails, the generation is deleted later, which starts a new one as well
+func (cache *RedisRepository) bumpGeneration(ctx context.Context, key string) {
+	pipe := cache.client.Pipeline()
+	pipe.SetNX(ctx, key, time.Now().UnixNano(), 0)
+	pipe.Incr(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		cache.invalidations.add(key)
+	}
+}
+
+// invalidate deletes keys, failed deletions are replayed before the cache is read again
+func (cache *RedisRepository) invalidate(ctx context.Context, keys ...string) {
+	if cache.client.Del(ctx, keys...).Err() != nil {
+		cache.invalidations.add(keys...)
+	}
+}
+
+// available reports if the cache can be read: pending invalidations must be replayed first
+func (cache *RedisRepository) available(ctx context.Context, key string) bool {
+	if cache.invalidations.replay(ctx, cache.client) != nil {
+		metrics.ObserveCacheBypass(key)
+		return false
+	}
+	return true
+}
+
+// readThroughPage serves a page cached under the current generation of the list or loads and caches it.
+// The generation is read before the page is loaded, so a page loaded before a write is never cached after it
+func readThroughPage[T any](ctx context.Context, cache *RedisRepository, generationKey string, keyOf func(generation string) string,
+	ttl time.Duration, load func(ctx context.Context) (T, error)) (T, error) {
+	if !cache.available(ctx, generationKey) {
+		return load(ctx)
+	}
+
+	generation, err := cache.generation(ctx, generationKey)
+	if err != nil {
+		metrics.ObserveCacheBypass(generationKey)
+		return load(ctx)
+	}
+
+	return readThrough(ctx, cache, keyOf(generation), ttl, nil, true, load)
+}
+
+// readThrough serves a cached value or loads it once for all concurrent requests of the key.
+// Hits shortly before expiration may refresh the value in background, see utils.RefreshEarly.
+// Errors matching notFound are cached as missingRecord for NegativeTTL. If redis fails, the value is loaded directly.
+// Pages are cached with compression
+func readThrough[T any](ctx context.Context, cache *RedisRepository, key string, ttl time.Duration, notFound error, page bool,
+	load func(ctx context.Context) (T, error)) (T, error) {
+	if !cache.available(ctx, key) {
+		return load(ctx)
+	}
+
+	pipe := cache.client.Pipeline()
+	get := pipe.Get(ctx, key)
+	remaining := pipe.PTTL(ctx, key)
+	_, _ = pipe.Exec(ctx)
+
+	switch serialized, err := get.Result(); {
 	case err == redis.Nil:
+		metrics.ObserveCacheLookup(key, false)
 		// continue execution
 	case err != nil:
-		return []model.FeedMetadataDocument{}, model.EmptyPage, fmt.Errorf("failed to get value from redis due to error %s", err)
+		metrics.ObserveCacheBypass(key)
+		return load(ctx)
+	case notFound != nil && serialized == missingRecord:
+		metrics.ObserveCacheLookup(key, true)
+		var value T
+		return value, notFound
 	default:
-		log.Printf("Successfully obtained first feed page from cache for key %s", key)
-		var record model.FeedPageCacheRecord
-		_ = json.Unmarshal([]byte(serialized), &record)
-
-		if size == len(record.FeedMetadata) {
-			return record.FeedMetadata, record.Page, nil
+		var value T
+		if cache.decode(ctx, key, serialized, &value) {
+			slog.DebugContext(ctx, "Cache hit", slog.String("key", key))
+			metrics.ObserveCacheLookup(key, true)
+			if utils.RefreshEarly(remaining.Val(), cache.ttl.EarlyRefresh) {
+				go func() { _, _ = loadOnce(ctx, cache, key, ttl, notFound, page, load) }()
+			}
+			return value, nil
 		}
+		metrics.ObserveCacheLookup(key, false)
 		// continue execution
 	}
 
-	feed, newPage, err := cache.persistentRepo.GetFeed(ctx, id, page, size)
+	return loadOnce(ctx, cache, key, ttl, notFound, page, load)
+}
 
-	if err == nil {
-		record := model.FeedPageCacheRecord{FeedMetadata: feed, Page: newPage}
-		serialized, _ := json.Marshal(record)
-		cache.client.Set(ctx, key, serialized, time.Hour)
-	}
+// detach separates a load shared by concurrent requests from the request which started it, so its cancellation
+// doesn't fail the others. The load is still limited, so a hung persistent repository doesn't hold them forever
+func (cache *RedisRepository) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cache.ttl.LoadTimeout)
+}
+
+// loadOnce loads and caches the value of the key, concurrent calls share the first one
+func loadOnce[T any](ctx context.Context, cache *RedisRepository, key string, ttl time.Duration, notFound error, page bool,
+	load func(ctx context.Context) (T, error)) (T, error) {
+	result, err, _ := cache.group.Do(key, func() (interface{}, error) {
+		ctx, cancel := cache.detach(ctx)
+		defer cancel()
+
+		value, err := load(ctx)
+
+		switch {
+		case err == nil:
+			if serialized, ok := cache.encode(ctx, key, value, page); ok {
+				cache.client.Set(ctx, key, serialized, cache.jitter(ttl))
+			}
+		case notFound != nil && errors.Is(err, notFound):
+			cache.client.Set(ctx, key, missingRecord, cache.ttl.NegativeTTL)
+		}
 
-	return feed, newPage, err
+		return value, err
+	})
+
+	value, _ := result.(T)
+	return value, err
 }
 
-func (cache *RedisRepository) AddPostToFeed(ctx context.Context, post model.FeedMetadataDocument) error {
-	err := cache.persistentRepo.AddPostToFeed(ctx, post)
+// encode serializes a cache entry, values which can't be encoded are not cached
+func (cache *RedisRepository) encode(ctx context.Context, key string, value any, page bool) ([]byte, bool) {
+	serialized, err := cache.format.Marshal(value, page)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to encode cache entry", slog.String("key", key), slog.Any("error", err))
+		return nil, false
+	}
+	return serialized, true
+}
 
-	if err == nil {
-		key := utils.CreateRedisKeyForFeedPage(post.UserId)
-		cache.client.Del(ctx, key)
+// decode treats entries which can't be decoded (e.g. of an unknown version) as misses, they are loaded again
+func (cache *RedisRepository) decode(ctx context.Context, key string, serialized string, value any) bool {
+	if err := codec.Unmarshal([]byte(serialized), value); err != nil {
+		slog.WarnContext(ctx, "Failed to decode cache entry", slog.String("key", key), slog.Any("error", err))
+		return false
 	}
+	return true
+}
 
-	return err
+func (cache *RedisRepository) jitter(ttl time.Duration) time.Duration {
+	return utils.Jitter(ttl, cache.ttl.TTLJitter)
 }
@@ -0,0 +1,819 @@
+package repo_test
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"microblog/internal/config"
+	"microblog/internal/model"
+	"microblog/internal/repo"
+	"microblog/internal/repo/repotest"
+	"microblog/internal/utils"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCachedRepository returns RedisRepository over an in-memory backend and an in-process redis
+func newCachedRepository(t *testing.T, opts ...func(cfg *config.Config)) (repo.Repository, repo.Repository, *miniredis.Miniredis) {
+	t.Helper()
+
+	backend := repo.NewMemoryRepository()
+	cached, server := newCachedRepositoryOver(t, backend, opts...)
+	return cached, backend, server
+}
+
+func newCachedRepositoryOver(t *testing.T, backend repo.Repository, opts ...func(cfg *config.Config)) (repo.Repository, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	return newCachedRepositoryOn(t, server, backend, opts...), server
+}
+
+// newCachedRepositoryOn returns RedisRepository which shares the redis with others
+func newCachedRepositoryOn(t *testing.T, server *miniredis.Miniredis, backend repo.Repository, opts ...func(cfg *config.Config)) repo.Repository {
+	t.Helper()
+
+	cfg := config.Default()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.Redis.Addr = server.Addr()
+	cfg.Retry.MaxAttempts = 1
+
+	cached, err := repo.NewRedisRepository(context.Background(), cfg, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cached.Close() })
+
+	return cached
+}
+
+func TestRedisRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repo.Repository {
+		cached, _, _ := newCachedRepository(t)
+		return cached
+	})
+
+	// pages beyond short timelines are read from the backend
+	t.Run("ShortTimelines", func(t *testing.T) {
+		repotest.Run(t, func(t *testing.T) repo.Repository {
+			cached, _, _ := newCachedRepository(t, withTimelineLength(3))
+			return cached
+		})
+	})
+
+	t.Run("HydratedFeedCache", func(t *testing.T) {
+		repotest.RunFeed(t, func(t *testing.T) repo.Repository {
+			cached, _, _ := newCachedRepository(t, withHydratedFeedCache)
+			return cached
+		})
+	})
+
+	for _, format := range [][2]string{
+		{config.CacheCodecMessagePack, config.CacheCompressionSnappy},
+		{config.CacheCodecProtobuf, config.CacheCompressionZstd},
+	} {
+		t.Run(format[0]+"/"+format[1], func(t *testing.T) {
+			repotest.Run(t, func(t *testing.T) repo.Repository {
+				cached, _, _ := newCachedRepository(t, withHydratedFeedCache, withFormat(format[0], format[1]))
+				return cached
+			})
+		})
+	}
+}
+
+func withFormat(codec, compression string) func(cfg *config.Config) {
+	return func(cfg *config.Config) {
+		cfg.Cache.Codec = codec
+		cfg.Cache.PageCompression = compression
+	}
+}
+
+func withHydratedFeedCache(cfg *config.Config) {
+	cfg.Cache.HydratedFeedTTL = time.Minute
+}
+
+func withTimelineLength(length int) func(cfg *config.Config) {
+	return func(cfg *config.Config) { cfg.Cache.TimelineLength = length }
+}
+
+func TestRedisRepositoryCacheCoherence(t *testing.T) {
+	ctx := context.Background()
+
+	// every case warms the cache up, changes the data through the cache and expects reads to agree with the backend
+	t.Run("PostIsCachedWithToken", func(t *testing.T) {
+		cached, backend, _ := newCachedRepository(t)
+		created := repotest.CreatePost(t, cached, "aa", "hello")
+
+		for i := 0; i < 2; i++ {
+			got, err := cached.GetPostById(ctx, created.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != created {
+				t.Fatalf("read %d: got %+v, want %+v", i, got, created)
+			}
+		}
+
+		want, _ := backend.GetPostById(ctx, created.Id)
+		if want != created {
+			t.Fatalf("backend has %+v, want %+v", want, created)
+		}
+	})
+
+	t.Run("PostPageIsCachedWithTokens", func(t *testing.T) {
+		cached, backend, _ := newCachedRepository(t)
+		for i := 0; i < 5; i++ {
+			repotest.CreatePost(t, cached, "aa", "post")
+		}
+
+		want := repotest.CollectPosts(t, backend, "aa", 2)
+		for i := 0; i < 2; i++ {
+			assertSamePosts(t, repotest.CollectPosts(t, cached, "aa", 2), want)
+		}
+	})
+
+	t.Run("EditUpdatesCachedPostAndPage", func(t *testing.T) {
+		cached, backend, _ := newCachedRepository(t)
+		created := repotest.CreatePost(t, cached, "aa", "hello")
+		repotest.CollectPosts(t, cached, "aa", 10)
+		_, _ = cached.GetPostById(ctx, created.Id)
+
+		edited, err := cached.EditPost(ctx, "aa", model.Post{Id: created.Id, Text: "edited"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := cached.GetPostById(ctx, created.Id)
+		if err != nil || got != edited {
+			t.Fatalf("got %+v (error %v), want %+v", got, err, edited)
+		}
+		assertSamePosts(t, repotest.CollectPosts(t, cached, "aa", 10), repotest.CollectPosts(t, backend, "aa", 10))
+	})
+
+	t.Run("CreateUpdatesTimeline", func(t *testing.T) {
+		cached, backend, _ := newCachedRepository(t)
+		repotest.CreatePost(t, cached, "aa", "first")
+		repotest.CollectPosts(t, cached, "aa", 1)
+
+		repotest.CreatePost(t, cached, "aa", "second")
+
+		assertSamePosts(t, repotest.CollectPosts(t, cached, "aa", 1), repotest.CollectPosts(t, backend, "aa", 1))
+	})
+
+	t.Run("DeepPagesAreCachedUntilEdit", func(t *testing.T) {
+		cached, backend, _ := newCachedRepository(t)
+		var oldest model.Post
+		for i := 0; i < 5; i++ {
+			post := repotest.CreatePost(t, cached, "aa", "post")
+			if i == 0 {
+				oldest = post
+			}
+		}
+		repotest.CollectPosts(t, cached, "aa", 2)
+
+		// a change behind the cache is not visible on the cached last page
+		if _, err := backend.EditPost(ctx, "aa", model.Post{Id: oldest.Id, Text: "behind"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := repotest.CollectPosts(t, cached, "aa", 2); got[len(got)-1].Text != "post" {
+			t.Fatalf("last page was not cached: %+v", got[len(got)-1])
+		}
+
+		// an edit through the cache replaces the cached post
+		if _, err := cached.EditPost(ctx, "aa", model.Post{Id: oldest.Id, Text: "edited"}); err != nil {
+			t.Fatal(err)
+		}
+		assertSamePosts(t, repotest.CollectPosts(t, cached, "aa", 2), repotest.CollectPosts(t, backend, "aa", 2))
+	})
+
+	t.Run("PagesBeyondTimelineAreCached", func(t *testing.T) {
+		backend := &listCountingRepository{Repository: repo.NewMemoryRepository()}
+		cached, _ := newCachedRepositoryOver(t, backend, withTimelineLength(2))
+		for i := 0; i < 6; i++ {
+			repotest.CreatePost(t, cached, "aa", "post")
+		}
+		items := repotest.NewFeedItems("bb", 6)
+		repotest.AddPostToFeed(t, cached, items...)
+		repotest.CollectPosts(t, cached, "aa", 2)
+		repotest.CollectFeed(t, cached, "bb", 2)
+
+		loads := backend.loads.Load()
+		repotest.CollectPosts(t, cached, "aa", 2)
+		assertSameFeed(t, repotest.CollectFeed(t, cached, "bb", 2), items)
+		if got := backend.loads.Load() - loads; got != 0 {
+			t.Fatalf("got %d loads of cached pages", got)
+		}
+
+		// writes start a new generation of the list
+		repotest.CreatePost(t, cached, "aa", "post")
+		if err := cached.RemovePostsFromFeed(ctx, "bb", []model.PostId{items[5].PostId}); err != nil {
+			t.Fatal(err)
+		}
+		assertSamePosts(t, repotest.CollectPosts(t, cached, "aa", 2), repotest.CollectPosts(t, backend.Repository, "aa", 2))
+		assertSameFeed(t, repotest.CollectFeed(t, cached, "bb", 2), items[:5])
+	})
+
+	t.Run("EvictedGenerationIsNotReused", func(t *testing.T) {
+		cached, backend, server := newCachedRepository(t, withHydratedFeedCache)
+		repotest.AddPostToFeed(t, cached, repotest.NewFeedItems("aa", 1)...)
+		_, _ = cached.GetFeedPosts(ctx, "aa", repotest.OlderPage(model.EmptyPage, 10))
+
+		server.Del(utils.CreateRedisKeyForFeedGeneration("aa"))
+		repotest.AddPostToFeed(t, cached, repotest.NewFeedItems("aa", 1)...)
+
+		got, err := cached.GetFeedPosts(ctx, "aa", repotest.OlderPage(model.EmptyPage, 10))
+		want, _ := backend.GetFeedPosts(ctx, "aa", repotest.OlderPage(model.EmptyPage, 10))
+		if err != nil || len(got.Dangling) != len(want.Dangling) {
+			t.Fatalf("got dangling %v (error %v), want %v", got.Dangling, err, want.Dangling)
+		}
+	})
+
+	t.Run("TimelineIsTrimmed", func(t *testing.T) {
+		cached, backend, server := newCachedRepository(t, withTimelineLength(2))
+		for i := 0; i < 5; i++ {
+			repotest.CreatePost(t, cached, "aa", "post")
+		}
+		repotest.CollectPosts(t, cached, "aa", 2)
+
+		members, err := server.ZMembers(utils.CreateRedisKeyForPostTimeline("aa"))
+		if err != nil || len(members) != 3 {
+			t.Fatalf("got timeline %v (error %v), want the marker and 2 posts", members, err)
+		}
+		assertSamePosts(t, repotest.CollectPosts(t, cached, "aa", 2), repotest.CollectPosts(t, backend, "aa", 2))
+	})
+
+	t.Run("WarmUpKeepsConcurrentWrites", func(t *testing.T) {
+		cached, backend, _ := newCachedRepository(t)
+		items := repotest.NewFeedItems("aa", 4)
+		repotest.AddPostToFeed(t, backend, items[1:]...)
+		// the timeline is created by the write before it is warmed up by a read
+		repotest.AddPostToFeed(t, cached, items[0])
+
+		assertSameFeed(t, repotest.CollectFeed(t, cached, "aa", 3), items)
+	})
+
+	t.Run("PageOfOtherSizeIsNotServed", func(t *testing.T) {
+		cached, backend, _ := newCachedRepository(t)
+		for i := 0; i < 5; i++ {
+			repotest.CreatePost(t, cached, "aa", "post")
+		}
+		repotest.CollectPosts(t, cached, "aa", 2)
+
+		assertSamePosts(t, repotest.CollectPosts(t, cached, "aa", 3), repotest.CollectPosts(t, backend, "aa", 3))
+	})
+
+	t.Run("SubscribeUpdatesUserLists", func(t *testing.T) {
+		backend := &countingRepository{Repository: repo.NewMemoryRepository()}
+		cached, _ := newCachedRepositoryOver(t, backend)
+		repotest.Subscribe(t, cached, "aa", "bb")
+		repotest.GetSubscribers(t, cached, "cc")
+
+		repotest.Subscribe(t, cached, "aa", "cc")
+		repotest.Subscribe(t, cached, "dd", "cc")
+
+		if got := repotest.GetSubscriptions(t, cached, "aa"); !slices.Equal(got, []model.UserId{"bb", "cc"}) {
+			t.Fatalf("got subscriptions %v, want [bb cc]", got)
+		}
+		if got := repotest.GetSubscribers(t, cached, "cc"); !slices.Equal(got, []model.UserId{"aa", "dd"}) {
+			t.Fatalf("got subscribers %v, want [aa dd]", got)
+		}
+		if loads := backend.loads.Load(); loads != 1 {
+			t.Fatalf("subscribers were loaded %d times, want once", loads)
+		}
+	})
+
+	t.Run("SubscribeDuringWarmUpIsKept", func(t *testing.T) {
+		backend := &countingRepository{Repository: repo.NewMemoryRepository(), release: make(chan struct{})}
+		cached, _ := newCachedRepositoryOver(t, backend)
+		repotest.Subscribe(t, cached, "aa", "cc")
+
+		warmed := make(chan error)
+		go func() {
+			_, err := cached.GetSubscribers(ctx, "cc")
+			warmed <- err
+		}()
+
+		// the list is loaded before the subscription and cached after it
+		for backend.loads.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		repotest.Subscribe(t, cached, "bb", "cc")
+		close(backend.release)
+		if err := <-warmed; err != nil {
+			t.Fatal(err)
+		}
+
+		if got := repotest.GetSubscribers(t, cached, "cc"); !slices.Equal(got, []model.UserId{"aa", "bb"}) {
+			t.Fatalf("got subscribers %v, want [aa bb]", got)
+		}
+	})
+
+	t.Run("DriftedUserListsAreReconciled", func(t *testing.T) {
+		cached, backend, _ := newCachedRepository(t, func(cfg *config.Config) {
+			cfg.Mode = config.ModeWorker
+			cfg.Cache.ReconcileInterval = 10 * time.Millisecond
+		})
+		repotest.Subscribe(t, cached, "aa", "bb")
+		repotest.GetSubscribers(t, cached, "bb")
+		repotest.GetSubscriptions(t, cached, "cc")
+
+		// writes which bypass the cache are picked up by the reconciliation
+		repotest.Subscribe(t, backend, "cc", "bb")
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			subscribers := repotest.GetSubscribers(t, cached, "bb")
+			subscriptions := repotest.GetSubscriptions(t, cached, "cc")
+			if slices.Equal(subscribers, []model.UserId{"aa", "cc"}) && slices.Equal(subscriptions, []model.UserId{"bb"}) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("got subscribers %v and subscriptions %v after reconciliation", subscribers, subscriptions)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	t.Run("AddPostToFeedUpdatesTimeline", func(t *testing.T) {
+		cached, backend, _ := newCachedRepository(t)
+		items := repotest.NewFeedItems("aa", 3)
+		repotest.AddPostToFeed(t, cached, items[1:]...)
+		repotest.CollectFeed(t, cached, "aa", 2)
+
+		repotest.AddPostToFeed(t, cached, items[0])
+
+		for i := 0; i < 2; i++ {
+			assertSameFeed(t, repotest.CollectFeed(t, cached, "aa", 2), repotest.CollectFeed(t, backend, "aa", 2))
+		}
+	})
+
+	t.Run("PostsByIdsAreCached", func(t *testing.T) {
+		cached, backend, _ := newCachedRepository(t)
+		first := repotest.CreatePost(t, backend, "aa", "first")
+		second := repotest.CreatePost(t, backend, "aa", "second")
+		_, _ = cached.GetPostById(ctx, first.Id)
+
+		// the first post is a hit, the second one is fetched and cached
+		for i := 0; i < 2; i++ {
+			got, err := cached.GetPostsByIds(ctx, []model.PostId{second.Id, first.Id})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSamePosts(t, got, []model.Post{second, first})
+		}
+	})
+
+	t.Run("HydratedFeedPageIsInvalidated", func(t *testing.T) {
+		cached, backend, _ := newCachedRepository(t, withHydratedFeedCache)
+		older := repotest.CreatePost(t, cached, "bb", "older")
+		repotest.AddPostToFeed(t, cached, model.FeedMetadataDocument{UserId: "aa", PostId: older.Id, Token: older.Token})
+		_, _ = cached.GetFeedPosts(ctx, "aa", repotest.OlderPage(model.EmptyPage, 10))
+
+		newer := repotest.CreatePost(t, cached, "bb", "newer")
+		repotest.AddPostToFeed(t, cached, model.FeedMetadataDocument{UserId: "aa", PostId: newer.Id, Token: newer.Token})
+
+		for i := 0; i < 2; i++ {
+			got, err := cached.GetFeedPosts(ctx, "aa", repotest.OlderPage(model.EmptyPage, 10))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := backend.GetFeedPosts(ctx, "aa", repotest.OlderPage(model.EmptyPage, 10))
+			assertSamePosts(t, got.Posts, want.Posts)
+			if got.Cursors != want.Cursors {
+				t.Fatalf("got cursors %+v, want %+v", got.Cursors, want.Cursors)
+			}
+		}
+	})
+
+	t.Run("UnknownPostIsCached", func(t *testing.T) {
+		backend := &countingRepository{Repository: repo.NewMemoryRepository()}
+		cached, _ := newCachedRepositoryOver(t, backend)
+		unknown := model.PostId(primitive.NewObjectID().Hex())
+
+		for i := 0; i < 3; i++ {
+			if _, err := cached.GetPostById(ctx, unknown); !errors.Is(err, model.PostNotFound) {
+				t.Fatalf("got error %v, want %v", err, model.PostNotFound)
+			}
+			if posts, err := cached.GetPostsByIds(ctx, []model.PostId{unknown}); err != nil || len(posts) != 0 {
+				t.Fatalf("got posts %v, error %v", posts, err)
+			}
+		}
+		if loads := backend.loads.Load(); loads != 1 {
+			t.Fatalf("unknown post was loaded %d times, want once", loads)
+		}
+
+		// posts which are created later are cached over the missing record
+		created := repotest.CreatePost(t, cached, "aa", "hello")
+		if got, err := cached.GetPostById(ctx, created.Id); err != nil || got != created {
+			t.Fatalf("got %+v (error %v), want %+v", got, err, created)
+		}
+	})
+
+	t.Run("ConcurrentMissesAreCoalesced", func(t *testing.T) {
+		backend := &countingRepository{Repository: repo.NewMemoryRepository(), release: make(chan struct{})}
+		cached, _ := newCachedRepositoryOver(t, backend)
+		created := repotest.CreatePost(t, backend.Repository, "aa", "hello")
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < cap(errs); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := cached.GetPostById(ctx, created.Id)
+				errs <- err
+			}()
+		}
+
+		// the first load is held until the others are waiting for it
+		for backend.loads.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(backend.release)
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if loads := backend.loads.Load(); loads != 1 {
+			t.Fatalf("post was loaded %d times, want once", loads)
+		}
+	})
+
+	t.Run("HotPostIsRefreshedEarly", func(t *testing.T) {
+		backend := &countingRepository{Repository: repo.NewMemoryRepository()}
+		cached, server := newCachedRepositoryOver(t, backend, func(cfg *config.Config) {
+			cfg.Cache.TTLJitter = 0
+			cfg.Cache.EarlyRefresh = cfg.Cache.PostTTL
+		})
+		created := repotest.CreatePost(t, cached, "aa", "hello")
+		if _, err := backend.EditPost(ctx, "aa", model.Post{Id: created.Id, Text: "edited"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// at the very end of the ttl a hit refreshes the entry almost surely
+		server.FastForward(time.Hour - time.Millisecond)
+		deadline := time.Now().Add(time.Second)
+		for {
+			got, err := cached.GetPostById(ctx, created.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Text == "edited" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("post was not refreshed before expiration")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	t.Run("OutageBypassesCache", func(t *testing.T) {
+		cached, backend, server := newCachedRepository(t, func(cfg *config.Config) {
+			cfg.Redis.BreakerThreshold = 1
+			cfg.Redis.BreakerCooldown = 20 * time.Millisecond
+			cfg.Redis.OperationTimeout = 100 * time.Millisecond
+		})
+		created := repotest.CreatePost(t, cached, "aa", "hello")
+		repotest.Subscribe(t, cached, "bb", "aa")
+		_, _ = cached.GetPostById(ctx, created.Id)
+		repotest.CollectPosts(t, cached, "aa", 10)
+		repotest.GetSubscriptions(t, cached, "bb")
+
+		server.Close()
+
+		// reads and writes succeed while redis is down, failed invalidations are queued
+		edited, err := cached.EditPost(ctx, "aa", model.Post{Id: created.Id, Text: "edited"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		repotest.CreatePost(t, cached, "aa", "second")
+		repotest.Subscribe(t, cached, "bb", "cc")
+
+		if got, err := cached.GetPostById(ctx, created.Id); err != nil || got != edited {
+			t.Fatalf("got %+v (error %v), want %+v", got, err, edited)
+		}
+		assertSamePosts(t, repotest.CollectPosts(t, cached, "aa", 10), repotest.CollectPosts(t, backend, "aa", 10))
+		if got := repotest.GetSubscriptions(t, cached, "bb"); len(got) != 2 {
+			t.Fatalf("got subscriptions %v, want [aa cc]", got)
+		}
+
+		// stale entries survived the restart, they are invalidated before the cache is read
+		if err = server.Restart(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(30 * time.Millisecond)
+
+		for i := 0; i < 2; i++ {
+			if got, err := cached.GetPostById(ctx, created.Id); err != nil || got != edited {
+				t.Fatalf("got %+v (error %v), want %+v", got, err, edited)
+			}
+			assertSamePosts(t, repotest.CollectPosts(t, cached, "aa", 10), repotest.CollectPosts(t, backend, "aa", 10))
+			if got := repotest.GetSubscriptions(t, cached, "bb"); len(got) != 2 {
+				t.Fatalf("got subscriptions %v, want [aa cc]", got)
+			}
+		}
+	})
+
+	t.Run("HealthIsOptional", func(t *testing.T) {
+		cached, _, server := newCachedRepository(t)
+		server.Close()
+
+		checks := cached.HealthChecks()
+		if checks[0].Name != "redis" || !checks[0].Optional || checks[0].Ping(ctx) == nil {
+			t.Fatalf("got redis health check %+v", checks[0])
+		}
+	})
+
+	t.Run("UndecodableEntryIsMiss", func(t *testing.T) {
+		cached, _, server := newCachedRepository(t)
+		created := repotest.CreatePost(t, cached, "aa", "hello")
+		key := utils.CreateRedisKeyForPost(created.Id)
+
+		for _, value := range []string{"\x1fentry of a newer version", "\x01\x01{broken"} {
+			if err := server.Set(key, value); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := cached.GetPostById(ctx, created.Id); err != nil || got != created {
+				t.Fatalf("got %+v (error %v), want %+v", got, err, created)
+			}
+			if posts, err := cached.GetPostsByIds(ctx, []model.PostId{created.Id}); err != nil || len(posts) != 1 || posts[0] != created {
+				t.Fatalf("got posts %+v (error %v), want %+v", posts, err, created)
+			}
+			if got, _ := server.Get(key); got == value {
+				t.Fatal("undecodable entry was not replaced")
+			}
+		}
+	})
+
+	t.Run("EntriesOfOtherFormatsAreRead", func(t *testing.T) {
+		backend := &countingRepository{Repository: repo.NewMemoryRepository()}
+		server := miniredis.RunT(t)
+		old := newCachedRepositoryOn(t, server, backend, withHydratedFeedCache)
+		updated := newCachedRepositoryOn(t, server, backend, withHydratedFeedCache,
+			withFormat(config.CacheCodecProtobuf, config.CacheCompressionZstd))
+
+		created := repotest.CreatePost(t, backend, "aa", "hello")
+		repotest.AddPostToFeed(t, backend, model.FeedMetadataDocument{UserId: "bb", PostId: created.Id, Token: created.Token})
+		want, err := old.GetFeedPosts(ctx, "bb", repotest.OlderPage(model.EmptyPage, 10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		loads := backend.loads.Load()
+
+		// servers with the new format read entries of old ones during a rollout, and the other way around
+		got, err := updated.GetFeedPosts(ctx, "bb", repotest.OlderPage(model.EmptyPage, 10))
+		if err != nil || len(got.Posts) != 1 || got.Posts[0] != want.Posts[0] {
+			t.Fatalf("got %+v (error %v), want %+v", got, err, want)
+		}
+		if posts, err := updated.GetPostsByIds(ctx, []model.PostId{created.Id}); err != nil || len(posts) != 1 || posts[0] != created {
+			t.Fatalf("got posts %+v (error %v), want %+v", posts, err, created)
+		}
+
+		edited, err := updated.EditPost(ctx, "aa", model.Post{Id: created.Id, Text: "edited"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := old.GetPostById(ctx, created.Id); err != nil || got != edited {
+			t.Fatalf("got %+v (error %v), want %+v", got, err, edited)
+		}
+		if backend.loads.Load() != loads {
+			t.Fatal("cached entries were loaded again")
+		}
+	})
+
+	t.Run("HungLoadsTimeOut", func(t *testing.T) {
+		backend := &stallingRepository{Repository: repo.NewMemoryRepository(), stalled: map[string]bool{}}
+		cached, _ := newCachedRepositoryOver(t, backend, func(cfg *config.Config) {
+			cfg.Cache.LoadTimeout = 50 * time.Millisecond
+		})
+		created := repotest.CreatePost(t, backend, "aa", "post")
+		repotest.Subscribe(t, backend, "bb", "aa")
+
+		// shared loads give up on a hung storage, warm-ups fall back to reading it directly
+		within(t, func() {
+			if _, err := cached.GetPostById(ctx, created.Id); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+			}
+		})
+		within(t, func() {
+			if posts, _, err := cached.GetPosts(ctx, "aa", repotest.OlderPage(model.EmptyPage, 10)); err != nil || len(posts) != 1 {
+				t.Errorf("got %d posts (error %v), want 1", len(posts), err)
+			}
+		})
+		within(t, func() {
+			if subscribers, err := cached.GetSubscribers(ctx, "aa"); err != nil || !slices.Equal(subscribers, []model.UserId{"bb"}) {
+				t.Errorf("got subscribers %v (error %v), want [bb]", subscribers, err)
+			}
+		})
+	})
+
+	t.Run("ConcurrentFeedMissesShareThePage", func(t *testing.T) {
+		backend := &gatedFeedRepository{Repository: repo.NewMemoryRepository(), release: make(chan struct{})}
+		cached, _ := newCachedRepositoryOver(t, backend, withHydratedFeedCache)
+		for i := 0; i < 3; i++ {
+			created := repotest.CreatePost(t, backend, "aa", "post")
+			repotest.AddPostToFeed(t, backend, model.FeedMetadataDocument{UserId: "bb", PostId: created.Id, Token: created.Token})
+		}
+
+		// every caller restores tokens of the single loaded page, run with -race to catch them writing it at once
+		var wg sync.WaitGroup
+		pages := make([]model.FeedPage, 8)
+		for i := range pages {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				page, err := cached.GetFeedPosts(ctx, "bb", repotest.OlderPage(model.EmptyPage, 10))
+				if err != nil {
+					t.Error(err)
+				}
+				pages[i] = page
+			}(i)
+		}
+		for backend.loads.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(backend.release)
+		wg.Wait()
+
+		for _, page := range pages {
+			if len(page.Posts) != 3 {
+				t.Fatalf("got %d posts, want 3", len(page.Posts))
+			}
+			for _, post := range page.Posts {
+				if post.Token.Hex() != string(post.Id) {
+					t.Fatalf("post %s has token %s", post.Id, post.Token.Hex())
+				}
+			}
+		}
+		if loads := backend.loads.Load(); loads != 1 {
+			t.Fatalf("feed was loaded %d times, want once", loads)
+		}
+	})
+
+	t.Run("FlushedCacheIsRebuilt", func(t *testing.T) {
+		cached, backend, server := newCachedRepository(t)
+		repotest.CreatePost(t, cached, "aa", "post")
+		repotest.CollectPosts(t, cached, "aa", 10)
+
+		server.FlushAll()
+
+		assertSamePosts(t, repotest.CollectPosts(t, cached, "aa", 10), repotest.CollectPosts(t, backend, "aa", 10))
+	})
+}
+
+func assertSamePosts(t *testing.T, got, want []model.Post) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d posts, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("post %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func assertSameFeed(t *testing.T, got, want []model.FeedMetadataDocument) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d feed items, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("feed item %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// countingRepository counts loads of posts by id, loads wait for release if it is set
+type countingRepository struct {
+	repo.Repository
+	loads   atomic.Int32
+	release chan struct{}
+}
+
+func (r *countingRepository) GetPostById(ctx context.Context, id model.PostId) (model.Post, error) {
+	r.loads.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	return r.Repository.GetPostById(ctx, id)
+}
+
+// GetSubscribers holds the list after it's read, so it may be stale when released
+func (r *countingRepository) GetSubscribers(ctx context.Context, id model.UserId) ([]model.UserId, error) {
+	subscribers, err := r.Repository.GetSubscribers(ctx, id)
+	r.loads.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	return subscribers, err
+}
+
+func (r *countingRepository) GetPostsByIds(ctx context.Context, ids []model.PostId) ([]model.Post, error) {
+	r.loads.Add(1)
+	return r.Repository.GetPostsByIds(ctx, ids)
+}
+
+// listCountingRepository counts loads of post lists and feeds
+type listCountingRepository struct {
+	repo.Repository
+	loads atomic.Int32
+}
+
+func (r *listCountingRepository) GetPosts(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.Post, model.PageCursors, error) {
+	r.loads.Add(1)
+	return r.Repository.GetPosts(ctx, id, page)
+}
+
+func (r *listCountingRepository) GetFeed(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.FeedMetadataDocument, model.PageCursors, error) {
+	r.loads.Add(1)
+	return r.Repository.GetFeed(ctx, id, page)
+}
+
+// gatedFeedRepository counts loads of feeds, they wait for release
+type gatedFeedRepository struct {
+	repo.Repository
+	loads   atomic.Int32
+	release chan struct{}
+}
+
+func (r *gatedFeedRepository) GetFeed(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.FeedMetadataDocument, model.PageCursors, error) {
+	r.loads.Add(1)
+	<-r.release
+	return r.Repository.GetFeed(ctx, id, page)
+}
+
+// stallingRepository hangs on the first call of each method until the context is done
+type stallingRepository struct {
+	repo.Repository
+	mu      sync.Mutex
+	stalled map[string]bool
+}
+
+func (r *stallingRepository) stall(ctx context.Context, method string) error {
+	r.mu.Lock()
+	stalled := r.stalled[method]
+	r.stalled[method] = true
+	r.mu.Unlock()
+
+	if stalled {
+		return nil
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (r *stallingRepository) GetPostById(ctx context.Context, id model.PostId) (model.Post, error) {
+	if err := r.stall(ctx, "GetPostById"); err != nil {
+		return model.Post{}, err
+	}
+	return r.Repository.GetPostById(ctx, id)
+}
+
+func (r *stallingRepository) GetPosts(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.Post, model.PageCursors, error) {
+	if err := r.stall(ctx, "GetPosts"); err != nil {
+		return nil, model.PageCursors{}, err
+	}
+	return r.Repository.GetPosts(ctx, id, page)
+}
+
+func (r *stallingRepository) GetSubscribers(ctx context.Context, id model.UserId) ([]model.UserId, error) {
+	if err := r.stall(ctx, "GetSubscribers"); err != nil {
+		return nil, err
+	}
+	return r.Repository.GetSubscribers(ctx, id)
+}
+
+// within fails the test if the call doesn't return in time instead of hanging it
+func within(t *testing.T, call func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		call()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("call didn't return in time")
+	}
+}
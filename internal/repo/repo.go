@@ -5,14 +5,35 @@ import (
 	"microblog/internal/model"
 )
 
+// HealthCheck pings a single external dependency of a repository
+type HealthCheck struct {
+	Name string
+	// Optional dependencies are not required to serve requests, the service is degraded without them
+	Optional bool
+	Ping     func(ctx context.Context) error
+}
+
 type Repository interface {
 	CreatePost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error)
 	EditPost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error)
 	GetPostById(ctx context.Context, id model.PostId) (model.Post, error)
-	GetPosts(ctx context.Context, id model.UserId, page model.PageToken, size int) ([]model.Post, model.PageToken, error)
+	// GetPostsByIds returns existing posts in the order of ids, missing posts are skipped
+	GetPostsByIds(ctx context.Context, ids []model.PostId) ([]model.Post, error)
+	// GetPosts returns the page next to the cursor. A cursor is a position in the order of tokens, it doesn't have
+	// to belong to the list: cursors of removed items and of other lists are valid
+	GetPosts(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.Post, model.PageCursors, error)
 	Subscribe(ctx context.Context, from model.UserId, to model.UserId) error
 	GetSubscriptions(ctx context.Context, id model.UserId) ([]model.UserId, error)
 	GetSubscribers(ctx context.Context, id model.UserId) ([]model.UserId, error)
-	GetFeed(ctx context.Context, id model.UserId, page model.PageToken, size int) ([]model.FeedMetadataDocument, model.PageToken, error)
+	// GetFeed returns the page next to the cursor, which is a position like in GetPosts
+	GetFeed(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.FeedMetadataDocument, model.PageCursors, error)
+	// CountFeed counts feed items newer than the cursor (all items for EmptyPage), but no more than limit
+	CountFeed(ctx context.Context, id model.UserId, since model.PageToken, limit int) (int, error)
+	// GetFeedPosts returns the page of the feed with posts resolved by a single batched lookup
+	GetFeedPosts(ctx context.Context, id model.UserId, page model.PageRequest) (model.FeedPage, error)
+	// AddPostToFeed adds the item once, adding an item with the same token again (e.g. by retried fan-out) does nothing
 	AddPostToFeed(ctx context.Context, post model.FeedMetadataDocument) error
+	RemovePostsFromFeed(ctx context.Context, id model.UserId, ids []model.PostId) error
+	HealthChecks() []HealthCheck
+	Close() error
 }
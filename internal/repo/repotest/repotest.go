@@ -0,0 +1,785 @@
+// Package repotest contains the contract every repo.Repository implementation must satisfy
+package repotest
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/rand"
+	"microblog/internal/model"
+	"microblog/internal/repo"
+	"testing"
+)
+
+// Factory returns a new empty repository, it is called once per test case
+type Factory func(t *testing.T) repo.Repository
+
+// Run runs the whole contract against repositories built by newRepo
+func Run(t *testing.T, newRepo Factory) {
+	t.Run("Posts", func(t *testing.T) { RunPosts(t, newRepo) })
+	t.Run("Pagination", func(t *testing.T) { RunPagination(t, newRepo) })
+	t.Run("Subscriptions", func(t *testing.T) { RunSubscriptions(t, newRepo) })
+	t.Run("Feed", func(t *testing.T) { RunFeed(t, newRepo) })
+}
+
+func RunPosts(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		r := newRepo(t)
+
+		created := CreatePost(t, r, "aa", "hello")
+		if created.Id == "" || created.Token.IsZero() || created.Id != model.PostId(created.Token.Hex()) {
+			t.Fatalf("post id %q does not match token %s", created.Id, created.Token.Hex())
+		}
+		if created.AuthorId != "aa" || created.Text != "hello" {
+			t.Fatalf("unexpected post %+v", created)
+		}
+		if created.CreatedAt == "" || created.CreatedAt != created.LastModifiedAt {
+			t.Fatalf("unexpected timestamps %q and %q", created.CreatedAt, created.LastModifiedAt)
+		}
+
+		got, err := r.GetPostById(ctx, created.Id)
+		if err != nil {
+			t.Fatalf("GetPostById: %v", err)
+		}
+		if got != created {
+			t.Fatalf("GetPostById returned %+v, want %+v", got, created)
+		}
+	})
+
+	t.Run("GetUnknown", func(t *testing.T) {
+		r := newRepo(t)
+
+		_, err := r.GetPostById(ctx, model.PostId(primitive.NewObjectID().Hex()))
+		if !errors.Is(err, model.PostNotFound) {
+			t.Fatalf("got error %v, want %v", err, model.PostNotFound)
+		}
+	})
+
+	t.Run("Edit", func(t *testing.T) {
+		r := newRepo(t)
+		created := CreatePost(t, r, "aa", "hello")
+
+		edited, err := r.EditPost(ctx, "aa", model.Post{Id: created.Id, Text: "edited"})
+		if err != nil {
+			t.Fatalf("EditPost: %v", err)
+		}
+		if edited.Text != "edited" || edited.Token != created.Token || edited.AuthorId != created.AuthorId || edited.CreatedAt != created.CreatedAt {
+			t.Fatalf("unexpected edited post %+v", edited)
+		}
+
+		got, err := r.GetPostById(ctx, created.Id)
+		if err != nil {
+			t.Fatalf("GetPostById: %v", err)
+		}
+		if got != edited {
+			t.Fatalf("GetPostById returned %+v, want %+v", got, edited)
+		}
+
+		posts := CollectPosts(t, r, "aa", 10)
+		if len(posts) != 1 || posts[0] != edited {
+			t.Fatalf("GetPosts returned %+v, want only %+v", posts, edited)
+		}
+	})
+
+	t.Run("EditUnknown", func(t *testing.T) {
+		r := newRepo(t)
+
+		_, err := r.EditPost(ctx, "aa", model.Post{Id: model.PostId(primitive.NewObjectID().Hex()), Text: "edited"})
+		if !errors.Is(err, model.PostNotFound) {
+			t.Fatalf("got error %v, want %v", err, model.PostNotFound)
+		}
+	})
+
+	t.Run("GetByIds", func(t *testing.T) {
+		r := newRepo(t)
+
+		first := CreatePost(t, r, "aa", "first")
+		second := CreatePost(t, r, "bb", "second")
+		unknown := model.PostId(primitive.NewObjectID().Hex())
+
+		// found posts keep the order of ids, unknown and malformed ids are skipped
+		posts, err := r.GetPostsByIds(ctx, []model.PostId{second.Id, unknown, first.Id, "not-an-id", second.Id})
+		if err != nil {
+			t.Fatalf("GetPostsByIds: %v", err)
+		}
+		assertPosts(t, posts, []model.Post{second, first, second})
+
+		// the second lookup may be served by a cache
+		posts, err = r.GetPostsByIds(ctx, []model.PostId{first.Id, unknown})
+		if err != nil {
+			t.Fatalf("GetPostsByIds: %v", err)
+		}
+		assertPosts(t, posts, []model.Post{first})
+
+		if posts, err = r.GetPostsByIds(ctx, nil); err != nil || len(posts) != 0 {
+			t.Fatalf("got %d posts, error %v for no ids", len(posts), err)
+		}
+	})
+}
+
+func RunPagination(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("Empty", func(t *testing.T) {
+		r := newRepo(t)
+
+		posts, next, err := r.GetPosts(ctx, "aa", OlderPage(model.EmptyPage, 10))
+		if err != nil {
+			t.Fatalf("GetPosts: %v", err)
+		}
+		if len(posts) != 0 || next.Older != model.EmptyPage {
+			t.Fatalf("got %d posts and token %q for a user without posts", len(posts), next.Older)
+		}
+	})
+
+	for _, tc := range []struct {
+		name  string
+		total int
+		size  int
+		pages []int
+	}{
+		{name: "SinglePartialPage", total: 3, size: 10, pages: []int{3}},
+		{name: "SingleFullPage", total: 10, size: 10, pages: []int{10}},
+		{name: "ExactMultiple", total: 6, size: 2, pages: []int{2, 2, 2}},
+		{name: "Remainder", total: 7, size: 3, pages: []int{3, 3, 1}},
+		// size 1 is the smallest page, a token must be produced even though only two posts are fetched
+		{name: "SizeOne", total: 3, size: 1, pages: []int{1, 1, 1}},
+		{name: "SizeOneSinglePost", total: 1, size: 1, pages: []int{1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newRepo(t)
+
+			var want []model.Post
+			for i := 0; i < tc.total; i++ {
+				want = append([]model.Post{CreatePost(t, r, "aa", "post")}, want...)
+			}
+			// posts of other users must not leak into the page
+			CreatePost(t, r, "bb", "other")
+
+			var got []model.Post
+			page := model.EmptyPage
+			for i, length := range tc.pages {
+				posts, next, err := r.GetPosts(ctx, "aa", OlderPage(page, tc.size))
+				if err != nil {
+					t.Fatalf("page %d: GetPosts: %v", i, err)
+				}
+				if len(posts) != length {
+					t.Fatalf("page %d: got %d posts, want %d", i, len(posts), length)
+				}
+
+				last := i == len(tc.pages)-1
+				if last != (next.Older == model.EmptyPage) {
+					t.Fatalf("page %d: unexpected next page token %q", i, next)
+				}
+
+				got = append(got, posts...)
+				page = next.Older
+			}
+
+			assertPosts(t, got, want)
+		})
+	}
+
+	t.Run("InsertBetweenPages", func(t *testing.T) {
+		r := newRepo(t)
+
+		var want []model.Post
+		for i := 0; i < 4; i++ {
+			want = append([]model.Post{CreatePost(t, r, "aa", "post")}, want...)
+		}
+
+		first, next, err := r.GetPosts(ctx, "aa", OlderPage(model.EmptyPage, 2))
+		if err != nil {
+			t.Fatalf("GetPosts: %v", err)
+		}
+
+		CreatePost(t, r, "aa", "newer")
+
+		second, next, err := r.GetPosts(ctx, "aa", OlderPage(next.Older, 2))
+		if err != nil {
+			t.Fatalf("GetPosts: %v", err)
+		}
+		if next.Older != model.EmptyPage {
+			t.Fatalf("unexpected next page token %q", next)
+		}
+
+		assertPosts(t, append(first, second...), want)
+	})
+
+	t.Run("Cursors", func(t *testing.T) {
+		r := newRepo(t)
+
+		var want []model.Post
+		for i := 0; i < 3; i++ {
+			want = append([]model.Post{CreatePost(t, r, "aa", "post")}, want...)
+		}
+
+		_, cursors, err := r.GetPosts(ctx, "aa", OlderPage(model.EmptyPage, 2))
+		if err != nil {
+			t.Fatalf("GetPosts: %v", err)
+		}
+		assertCursors(t, cursors, model.PageCursors{Older: cursorOf(want[1].Token), Newer: cursorOf(want[0].Token)})
+
+		// the oldest page keeps the position of its newest post
+		_, cursors, err = r.GetPosts(ctx, "aa", OlderPage(cursors.Older, 2))
+		if err != nil {
+			t.Fatalf("GetPosts: %v", err)
+		}
+		assertCursors(t, cursors, model.PageCursors{Older: model.EmptyPage, Newer: cursorOf(want[2].Token)})
+	})
+
+	t.Run("NewerPages", func(t *testing.T) {
+		r := newRepo(t)
+
+		var want []model.Post
+		for i := 0; i < 7; i++ {
+			want = append([]model.Post{CreatePost(t, r, "aa", "post")}, want...)
+		}
+		CreatePost(t, r, "bb", "other")
+
+		// pages closest to the cursor go first, every page is ordered from the newest post
+		since := cursorOf(want[6].Token)
+		for _, page := range [][]model.Post{want[3:6], want[0:3]} {
+			posts, cursors, err := r.GetPosts(ctx, "aa", NewerPage(since, 3))
+			if err != nil {
+				t.Fatalf("GetPosts: %v", err)
+			}
+			assertPosts(t, posts, page)
+			assertCursors(t, cursors, model.PageCursors{Older: cursorOf(page[2].Token), Newer: cursorOf(page[0].Token)})
+			since = cursors.Newer
+		}
+
+		posts, cursors, err := r.GetPosts(ctx, "aa", NewerPage(since, 3))
+		if err != nil {
+			t.Fatalf("GetPosts: %v", err)
+		}
+		assertPosts(t, posts, []model.Post{})
+		assertCursors(t, cursors, model.PageCursors{Older: model.EmptyPage, Newer: since})
+
+		fresh := CreatePost(t, r, "aa", "fresh")
+		posts, _, err = r.GetPosts(ctx, "aa", NewerPage(since, 3))
+		if err != nil {
+			t.Fatalf("GetPosts: %v", err)
+		}
+		assertPosts(t, posts, []model.Post{fresh})
+	})
+
+	t.Run("NewerWithoutCursor", func(t *testing.T) {
+		r := newRepo(t)
+
+		var want []model.Post
+		for i := 0; i < 3; i++ {
+			want = append([]model.Post{CreatePost(t, r, "aa", "post")}, want...)
+		}
+
+		posts, cursors, err := r.GetPosts(ctx, "aa", NewerPage(model.EmptyPage, 2))
+		if err != nil {
+			t.Fatalf("GetPosts: %v", err)
+		}
+		assertPosts(t, posts, want[:2])
+		assertCursors(t, cursors, model.PageCursors{Older: cursorOf(want[1].Token), Newer: cursorOf(want[0].Token)})
+	})
+
+	t.Run("MalformedToken", func(t *testing.T) {
+		r := newRepo(t)
+		CreatePost(t, r, "aa", "post")
+
+		_, _, err := r.GetPosts(ctx, "aa", OlderPage("not-a-token", 10))
+		if !errors.Is(err, model.InvalidPageToken) {
+			t.Fatalf("got error %v, want %v", err, model.InvalidPageToken)
+		}
+	})
+
+	// ownership of a token is checked by the service, a repository treats it as a position
+	t.Run("ForeignCursor", func(t *testing.T) {
+		r := newRepo(t)
+		older := []model.Post{CreatePost(t, r, "aa", "first"), CreatePost(t, r, "aa", "second")}
+		foreign := CreatePost(t, r, "bb", "foreign")
+		CreatePost(t, r, "aa", "third")
+
+		posts, next, err := r.GetPosts(ctx, "aa", OlderPage(model.PageToken(foreign.Token.Hex()), 10))
+		if err != nil || next.Older != model.EmptyPage {
+			t.Fatalf("GetPosts: token %q, error %v", next.Older, err)
+		}
+		assertPosts(t, posts, []model.Post{older[1], older[0]})
+	})
+
+	t.Run("ForeignCursorNewer", func(t *testing.T) {
+		r := newRepo(t)
+		CreatePost(t, r, "aa", "first")
+		foreign := CreatePost(t, r, "bb", "foreign")
+		newer := []model.Post{CreatePost(t, r, "aa", "second"), CreatePost(t, r, "aa", "third")}
+
+		posts, _, err := r.GetPosts(ctx, "aa", NewerPage(model.PageToken(foreign.Token.Hex()), 10))
+		if err != nil {
+			t.Fatalf("GetPosts: %v", err)
+		}
+		assertPosts(t, posts, []model.Post{newer[1], newer[0]})
+	})
+
+	t.Run("UnknownCursor", func(t *testing.T) {
+		r := newRepo(t)
+		post := CreatePost(t, r, "aa", "post")
+
+		// a fresh cursor is newer than every post
+		posts, next, err := r.GetPosts(ctx, "aa", OlderPage(model.PageToken(primitive.NewObjectID().Hex()), 10))
+		if err != nil || next.Older != model.EmptyPage {
+			t.Fatalf("GetPosts: token %q, error %v", next.Older, err)
+		}
+		assertPosts(t, posts, []model.Post{post})
+	})
+}
+
+func RunSubscriptions(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("Empty", func(t *testing.T) {
+		r := newRepo(t)
+
+		assertUsers(t, "subscriptions", GetSubscriptions(t, r, "aa"), nil)
+		assertUsers(t, "subscribers", GetSubscribers(t, r, "aa"), nil)
+	})
+
+	t.Run("Subscribe", func(t *testing.T) {
+		r := newRepo(t)
+
+		Subscribe(t, r, "aa", "bb")
+		Subscribe(t, r, "aa", "cc")
+		Subscribe(t, r, "dd", "bb")
+
+		assertUsers(t, "subscriptions of aa", GetSubscriptions(t, r, "aa"), []model.UserId{"bb", "cc"})
+		assertUsers(t, "subscribers of bb", GetSubscribers(t, r, "bb"), []model.UserId{"aa", "dd"})
+		assertUsers(t, "subscribers of cc", GetSubscribers(t, r, "cc"), []model.UserId{"aa"})
+		assertUsers(t, "subscriptions of bb", GetSubscriptions(t, r, "bb"), nil)
+	})
+
+	t.Run("AlreadySubscribed", func(t *testing.T) {
+		r := newRepo(t)
+
+		Subscribe(t, r, "aa", "bb")
+		// warm up caches, if any
+		GetSubscriptions(t, r, "aa")
+		GetSubscribers(t, r, "bb")
+
+		if err := r.Subscribe(ctx, "aa", "bb"); !errors.Is(err, model.AlreadySubscribed) {
+			t.Fatalf("got error %v, want %v", err, model.AlreadySubscribed)
+		}
+
+		assertUsers(t, "subscriptions of aa", GetSubscriptions(t, r, "aa"), []model.UserId{"bb"})
+		assertUsers(t, "subscribers of bb", GetSubscribers(t, r, "bb"), []model.UserId{"aa"})
+	})
+
+	t.Run("Self", func(t *testing.T) {
+		r := newRepo(t)
+
+		if err := r.Subscribe(ctx, "aa", "aa"); !errors.Is(err, model.SelfSubscription) {
+			t.Fatalf("got error %v, want %v", err, model.SelfSubscription)
+		}
+
+		assertUsers(t, "subscriptions of aa", GetSubscriptions(t, r, "aa"), nil)
+		assertUsers(t, "subscribers of aa", GetSubscribers(t, r, "aa"), nil)
+	})
+}
+
+func RunFeed(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("Empty", func(t *testing.T) {
+		r := newRepo(t)
+
+		feed, next, err := r.GetFeed(ctx, "aa", OlderPage(model.EmptyPage, 10))
+		if err != nil {
+			t.Fatalf("GetFeed: %v", err)
+		}
+		if len(feed) != 0 || next.Older != model.EmptyPage {
+			t.Fatalf("got %d items and token %q for an empty feed", len(feed), next.Older)
+		}
+	})
+
+	t.Run("NewestFirst", func(t *testing.T) {
+		r := newRepo(t)
+
+		want := NewFeedItems("aa", 7)
+		for _, i := range rand.Perm(len(want)) {
+			AddPostToFeed(t, r, want[i])
+		}
+		AddPostToFeed(t, r, NewFeedItems("bb", 1)...)
+
+		assertFeed(t, CollectFeed(t, r, "aa", 3), want)
+	})
+
+	// retried fan-out adds items again
+	t.Run("DuplicateItemsAreAddedOnce", func(t *testing.T) {
+		r := newRepo(t)
+
+		want := NewFeedItems("aa", 3)
+		AddPostToFeed(t, r, want...)
+		AddPostToFeed(t, r, want[1], want[0])
+
+		assertFeed(t, CollectFeed(t, r, "aa", 2), want)
+		if count, err := r.CountFeed(ctx, "aa", model.EmptyPage, 10); err != nil || count != len(want) {
+			t.Fatalf("got count %d (error %v), want %d", count, err, len(want))
+		}
+	})
+
+	t.Run("ExactMultiple", func(t *testing.T) {
+		r := newRepo(t)
+
+		want := NewFeedItems("aa", 4)
+		AddPostToFeed(t, r, want...)
+
+		first, next, err := r.GetFeed(ctx, "aa", OlderPage(model.EmptyPage, 2))
+		if err != nil || next.Older == model.EmptyPage {
+			t.Fatalf("GetFeed: token %q, error %v", next.Older, err)
+		}
+		second, next, err := r.GetFeed(ctx, "aa", OlderPage(next.Older, 2))
+		if err != nil || next.Older != model.EmptyPage {
+			t.Fatalf("GetFeed: token %q, error %v", next.Older, err)
+		}
+
+		assertFeed(t, append(first, second...), want)
+	})
+
+	t.Run("SizeOne", func(t *testing.T) {
+		r := newRepo(t)
+
+		want := NewFeedItems("aa", 3)
+		AddPostToFeed(t, r, want...)
+
+		assertFeed(t, CollectFeed(t, r, "aa", 1), want)
+	})
+
+	t.Run("SharedPost", func(t *testing.T) {
+		r := newRepo(t)
+
+		// the same post is streamed into feeds of several subscribers, its token is valid in each of them
+		shared := NewFeedItems("aa", 3)
+		for _, item := range shared {
+			AddPostToFeed(t, r, item, model.FeedMetadataDocument{UserId: "bb", PostId: item.PostId, Token: item.Token})
+		}
+
+		for _, user := range []model.UserId{"aa", "bb"} {
+			if feed := CollectFeed(t, r, user, 1); len(feed) != len(shared) {
+				t.Fatalf("feed of %s has %d items, want %d", user, len(feed), len(shared))
+			}
+		}
+	})
+
+	t.Run("ForeignCursor", func(t *testing.T) {
+		r := newRepo(t)
+		older := NewFeedItems("bb", 2)
+		AddPostToFeed(t, r, older...)
+		AddPostToFeed(t, r, NewFeedItems("aa", 3)...)
+		AddPostToFeed(t, r, NewFeedItems("bb", 1)...)
+
+		_, next, err := r.GetFeed(ctx, "aa", OlderPage(model.EmptyPage, 1))
+		if err != nil || next.Older == model.EmptyPage {
+			t.Fatalf("GetFeed: token %q, error %v", next.Older, err)
+		}
+
+		feed, next, err := r.GetFeed(ctx, "bb", OlderPage(next.Older, 10))
+		if err != nil || next.Older != model.EmptyPage {
+			t.Fatalf("GetFeed: token %q, error %v", next.Older, err)
+		}
+		assertFeed(t, feed, older)
+	})
+
+	// a cursor of a removed item keeps its position, so clients paging through the feed don't fail
+	t.Run("RemovedCursor", func(t *testing.T) {
+		r := newRepo(t)
+		items := NewFeedItems("aa", 5)
+		AddPostToFeed(t, r, items...)
+		if err := r.RemovePostsFromFeed(ctx, "aa", []model.PostId{items[2].PostId}); err != nil {
+			t.Fatalf("RemovePostsFromFeed: %v", err)
+		}
+
+		older, _, err := r.GetFeed(ctx, "aa", OlderPage(cursorOf(items[2].Token), 10))
+		if err != nil {
+			t.Fatalf("GetFeed: %v", err)
+		}
+		assertFeed(t, older, items[3:])
+
+		newer, _, err := r.GetFeed(ctx, "aa", NewerPage(cursorOf(items[2].Token), 10))
+		if err != nil {
+			t.Fatalf("GetFeed: %v", err)
+		}
+		assertFeed(t, newer, items[:2])
+	})
+
+	t.Run("UnknownCursor", func(t *testing.T) {
+		r := newRepo(t)
+		items := NewFeedItems("aa", 2)
+		AddPostToFeed(t, r, items...)
+
+		// a fresh cursor is newer than every item
+		cursor := cursorOf(primitive.NewObjectID())
+		older, _, err := r.GetFeed(ctx, "aa", OlderPage(cursor, 10))
+		if err != nil {
+			t.Fatalf("GetFeed: %v", err)
+		}
+		assertFeed(t, older, items)
+
+		newer, _, err := r.GetFeed(ctx, "aa", NewerPage(cursor, 10))
+		if err != nil {
+			t.Fatalf("GetFeed: %v", err)
+		}
+		assertFeed(t, newer, nil)
+	})
+
+	t.Run("NewerPages", func(t *testing.T) {
+		r := newRepo(t)
+
+		want := NewFeedItems("aa", 5)
+		AddPostToFeed(t, r, want...)
+		AddPostToFeed(t, r, NewFeedItems("bb", 1)...)
+
+		feed, cursors, err := r.GetFeed(ctx, "aa", NewerPage(cursorOf(want[4].Token), 3))
+		if err != nil {
+			t.Fatalf("GetFeed: %v", err)
+		}
+		assertFeed(t, feed, want[1:4])
+		assertCursors(t, cursors, model.PageCursors{Older: cursorOf(want[3].Token), Newer: cursorOf(want[1].Token)})
+
+		feed, cursors, err = r.GetFeed(ctx, "aa", NewerPage(cursors.Newer, 3))
+		if err != nil {
+			t.Fatalf("GetFeed: %v", err)
+		}
+		assertFeed(t, feed, want[:1])
+		assertCursors(t, cursors, model.PageCursors{Older: cursorOf(want[0].Token), Newer: cursorOf(want[0].Token)})
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		r := newRepo(t)
+
+		items := NewFeedItems("aa", 5)
+		AddPostToFeed(t, r, items...)
+		AddPostToFeed(t, r, NewFeedItems("bb", 2)...)
+
+		for _, tc := range []struct {
+			name  string
+			since model.PageToken
+			limit int
+			want  int
+		}{
+			{name: "All", since: model.EmptyPage, limit: 10, want: 5},
+			{name: "Since", since: cursorOf(items[2].Token), limit: 10, want: 2},
+			{name: "SinceNewest", since: cursorOf(items[0].Token), limit: 10, want: 0},
+			{name: "Limit", since: model.EmptyPage, limit: 3, want: 3},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				count, err := r.CountFeed(ctx, "aa", tc.since, tc.limit)
+				if err != nil || count != tc.want {
+					t.Fatalf("got count %d, error %v, want %d", count, err, tc.want)
+				}
+			})
+		}
+
+		if _, err := r.CountFeed(ctx, "aa", "not-a-token", 10); !errors.Is(err, model.InvalidPageToken) {
+			t.Fatalf("got error %v, want %v", err, model.InvalidPageToken)
+		}
+	})
+
+	t.Run("Hydrated", func(t *testing.T) {
+		r := newRepo(t)
+
+		var posts []model.Post
+		for i := 0; i < 3; i++ {
+			post := CreatePost(t, r, "bb", "post")
+			AddPostToFeed(t, r, model.FeedMetadataDocument{UserId: "aa", PostId: post.Id, Token: post.Token})
+			posts = append([]model.Post{post}, posts...)
+		}
+		// feed item of a post which does not exist, e.g. it was deleted after fan-out
+		dangling := NewFeedItems("aa", 1)[0]
+		AddPostToFeed(t, r, dangling)
+
+		page, err := r.GetFeedPosts(ctx, "aa", OlderPage(model.EmptyPage, 2))
+		if err != nil {
+			t.Fatalf("GetFeedPosts: %v", err)
+		}
+		assertPosts(t, page.Posts, posts[:1])
+		if len(page.Dangling) != 1 || page.Dangling[0] != dangling.PostId {
+			t.Fatalf("got dangling %v, want [%s]", page.Dangling, dangling.PostId)
+		}
+		assertCursors(t, page.Cursors, model.PageCursors{Older: cursorOf(posts[0].Token), Newer: cursorOf(dangling.Token)})
+
+		page, err = r.GetFeedPosts(ctx, "aa", OlderPage(page.Cursors.Older, 2))
+		if err != nil || len(page.Dangling) != 0 {
+			t.Fatalf("GetFeedPosts: dangling %v, error %v", page.Dangling, err)
+		}
+		assertPosts(t, page.Posts, posts[1:])
+
+		if err = r.RemovePostsFromFeed(ctx, "aa", []model.PostId{dangling.PostId}); err != nil {
+			t.Fatalf("RemovePostsFromFeed: %v", err)
+		}
+
+		page, err = r.GetFeedPosts(ctx, "aa", OlderPage(model.EmptyPage, 2))
+		if err != nil || len(page.Dangling) != 0 {
+			t.Fatalf("GetFeedPosts: dangling %v, error %v", page.Dangling, err)
+		}
+		assertPosts(t, page.Posts, posts[:2])
+		if feed := CollectFeed(t, r, "aa", 10); len(feed) != len(posts) {
+			t.Fatalf("got %d feed items after removal, want %d", len(feed), len(posts))
+		}
+	})
+
+	t.Run("MalformedToken", func(t *testing.T) {
+		r := newRepo(t)
+		AddPostToFeed(t, r, NewFeedItems("aa", 1)...)
+
+		_, _, err := r.GetFeed(ctx, "aa", OlderPage("not-a-token", 10))
+		if !errors.Is(err, model.InvalidPageToken) {
+			t.Fatalf("got error %v, want %v", err, model.InvalidPageToken)
+		}
+	})
+}
+
+func CreatePost(t *testing.T, r repo.Repository, author model.UserId, text string) model.Post {
+	t.Helper()
+
+	post, err := r.CreatePost(context.Background(), author, model.Post{Text: text})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	return post
+}
+
+func Subscribe(t *testing.T, r repo.Repository, from, to model.UserId) {
+	t.Helper()
+
+	if err := r.Subscribe(context.Background(), from, to); err != nil {
+		t.Fatalf("Subscribe(%s, %s): %v", from, to, err)
+	}
+}
+
+func GetSubscriptions(t *testing.T, r repo.Repository, id model.UserId) []model.UserId {
+	t.Helper()
+
+	ids, err := r.GetSubscriptions(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetSubscriptions: %v", err)
+	}
+	return ids
+}
+
+func GetSubscribers(t *testing.T, r repo.Repository, id model.UserId) []model.UserId {
+	t.Helper()
+
+	ids, err := r.GetSubscribers(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetSubscribers: %v", err)
+	}
+	return ids
+}
+
+// NewFeedItems returns n feed items of the user ordered from the newest to the oldest
+func NewFeedItems(user model.UserId, n int) []model.FeedMetadataDocument {
+	items := make([]model.FeedMetadataDocument, n)
+	for i := n - 1; i >= 0; i-- {
+		token := primitive.NewObjectID()
+		items[i] = model.FeedMetadataDocument{UserId: user, PostId: model.PostId(token.Hex()), Token: token}
+	}
+	return items
+}
+
+func OlderPage(cursor model.PageToken, size int) model.PageRequest {
+	return model.PageRequest{Cursor: cursor, Direction: model.DirectionOlder, Size: size}
+}
+
+func NewerPage(cursor model.PageToken, size int) model.PageRequest {
+	return model.PageRequest{Cursor: cursor, Direction: model.DirectionNewer, Size: size}
+}
+
+func AddPostToFeed(t *testing.T, r repo.Repository, items ...model.FeedMetadataDocument) {
+	t.Helper()
+
+	for _, item := range items {
+		if err := r.AddPostToFeed(context.Background(), item); err != nil {
+			t.Fatalf("AddPostToFeed: %v", err)
+		}
+	}
+}
+
+// CollectPosts walks through all pages of user posts
+func CollectPosts(t *testing.T, r repo.Repository, id model.UserId, size int) []model.Post {
+	t.Helper()
+
+	var result []model.Post
+	for page, first := model.EmptyPage, true; first || page != model.EmptyPage; first = false {
+		posts, next, err := r.GetPosts(context.Background(), id, OlderPage(page, size))
+		if err != nil {
+			t.Fatalf("GetPosts(%s, %q, %d): %v", id, page, size, err)
+		}
+		result = append(result, posts...)
+		page = next.Older
+	}
+	return result
+}
+
+// CollectFeed walks through all pages of user feed
+func CollectFeed(t *testing.T, r repo.Repository, id model.UserId, size int) []model.FeedMetadataDocument {
+	t.Helper()
+
+	var result []model.FeedMetadataDocument
+	for page, first := model.EmptyPage, true; first || page != model.EmptyPage; first = false {
+		feed, next, err := r.GetFeed(context.Background(), id, OlderPage(page, size))
+		if err != nil {
+			t.Fatalf("GetFeed(%s, %q, %d): %v", id, page, size, err)
+		}
+		result = append(result, feed...)
+		page = next.Older
+	}
+	return result
+}
+
+func assertPosts(t *testing.T, got, want []model.Post) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d posts, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("post %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func cursorOf(token primitive.ObjectID) model.PageToken {
+	return model.PageToken(token.Hex())
+}
+
+func assertCursors(t *testing.T, got, want model.PageCursors) {
+	t.Helper()
+
+	if got != want {
+		t.Fatalf("got cursors %+v, want %+v", got, want)
+	}
+}
+
+func assertFeed(t *testing.T, got, want []model.FeedMetadataDocument) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d feed items, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("feed item %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func assertUsers(t *testing.T, name string, got, want []model.UserId) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %v, want %v", name, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
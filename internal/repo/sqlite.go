@@ -0,0 +1,396 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io/fs"
+	"log/slog"
+	"microblog/internal/config"
+	"microblog/internal/metrics"
+	"microblog/internal/model"
+	"microblog/internal/utils"
+	_ "modernc.org/sqlite"
+	"path"
+	"strings"
+	"time"
+)
+
+var _ Repository = (*SQLiteRepository)(nil)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// sqliteRow is either *sql.Row or *sql.Rows
+type sqliteRow interface {
+	Scan(dest ...any) error
+}
+
+// SQLiteRepository keeps all data in a local database file, it is meant for single-node deployments.
+// Queries are shared with PostgresRepository where both dialects agree
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(ctx context.Context, cfg config.Config) (Repository, error) {
+	// writes wait for each other instead of failing with SQLITE_BUSY, WAL lets reads run alongside a write.
+	// Transactions take the write lock at once, so concurrent migrations are applied one after another
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate",
+		cfg.SQLite.Path, cfg.SQLite.BusyTimeout.Milliseconds())
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database %s: %w", cfg.SQLite.Path, err)
+	}
+
+	storage := &SQLiteRepository{db: db}
+
+	if err = storage.migrate(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+// migrate applies embedded migrations which were not applied yet in the order of their file names
+func (storage *SQLiteRepository) migrate(ctx context.Context) error {
+	files, err := fs.Glob(sqliteMigrations, "migrations/sqlite/*.sql")
+	if err != nil {
+		return err
+	}
+
+	tx, err := storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to migrate sqlite: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to migrate sqlite: %w", err)
+	}
+
+	for _, file := range files {
+		version := path.Base(file)
+
+		var applied int
+		err = tx.QueryRowContext(ctx, "SELECT count(*) FROM schema_migrations WHERE version = $1", version).Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("failed to migrate sqlite: %w", err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		migration, err := sqliteMigrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, string(migration)); err != nil {
+			return fmt.Errorf("failed to apply sqlite migration %s: %w", version, err)
+		}
+		if _, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)", version, string(utils.Now())); err != nil {
+			return fmt.Errorf("failed to apply sqlite migration %s: %w", version, err)
+		}
+
+		slog.InfoContext(ctx, "Applied sqlite migration", slog.String("version", version))
+	}
+
+	return tx.Commit()
+}
+
+func (storage *SQLiteRepository) HealthChecks() []HealthCheck {
+	return []HealthCheck{{
+		Name: "sqlite",
+		Ping: func(ctx context.Context) error {
+			return storage.db.PingContext(ctx)
+		},
+	}}
+}
+
+func (storage *SQLiteRepository) Close() error {
+	return storage.db.Close()
+}
+
+func (storage *SQLiteRepository) CreatePost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error) {
+	defer metrics.ObserveSQLiteOperation("create_post", time.Now())
+
+	post.Token = primitive.NewObjectID()
+	post.Id = model.PostId(post.Token.Hex())
+	post.AuthorId = id
+	post.CreatedAt = utils.Now()
+	post.LastModifiedAt = post.CreatedAt
+
+	_, err := storage.db.ExecContext(ctx, "INSERT INTO posts ("+postColumns+") VALUES ($1, $2, $3, $4, $4)",
+		post.Token[:], string(post.AuthorId), post.Text, string(post.CreatedAt))
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to insert post", slog.String("author_id", string(id)), slog.Any("error", err))
+		err = model.PostCreationFailed
+	}
+
+	return post, err
+}
+
+func (storage *SQLiteRepository) EditPost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error) {
+	defer metrics.ObserveSQLiteOperation("edit_post", time.Now())
+
+	token, err := primitive.ObjectIDFromHex(string(post.Id))
+	if err != nil {
+		return model.Post{}, model.PostNotFound
+	}
+
+	row := storage.db.QueryRowContext(ctx, "UPDATE posts SET text = $2, last_modified_at = $3 WHERE token = $1 RETURNING "+postColumns,
+		token[:], post.Text, string(utils.Now()))
+	result, err := scanSQLitePost(row)
+
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		err = model.PostNotFound
+	}
+
+	return result, err
+}
+
+func (storage *SQLiteRepository) GetPostById(ctx context.Context, id model.PostId) (model.Post, error) {
+	defer metrics.ObserveSQLiteOperation("get_post_by_id", time.Now())
+
+	// ids which are not tokens can't belong to existing posts
+	token, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return model.Post{}, model.PostNotFound
+	}
+
+	row := storage.db.QueryRowContext(ctx, "SELECT "+postColumns+" FROM posts WHERE token = $1", token[:])
+	result, err := scanSQLitePost(row)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		err = model.PostNotFound
+	}
+	return result, err
+}
+
+func (storage *SQLiteRepository) GetPostsByIds(ctx context.Context, ids []model.PostId) ([]model.Post, error) {
+	defer metrics.ObserveSQLiteOperation("get_posts_by_ids", time.Now())
+
+	var tokens []any
+	for _, id := range ids {
+		// ids which are not tokens can't belong to existing posts
+		if token, err := primitive.ObjectIDFromHex(string(id)); err == nil {
+			tokens = append(tokens, token[:])
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	rows, err := storage.db.QueryContext(ctx, "SELECT "+postColumns+" FROM posts WHERE token IN ("+placeholders(1, len(tokens))+")", tokens...)
+	if err != nil {
+		return nil, err
+	}
+	found, err := collectSQLiteRows(rows, scanSQLitePost)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderPosts(ids, found), nil
+}
+
+func (storage *SQLiteRepository) GetPosts(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.Post, model.PageCursors, error) {
+	defer metrics.ObserveSQLiteOperation("get_posts", time.Now())
+
+	query, args, err := keysetQuery("SELECT "+postColumns+" FROM posts WHERE author_id = $1", string(id), page)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	rows, err := storage.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+	result, err := collectSQLiteRows(rows, scanSQLitePost)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	result, cursors := pageOf(result, page, func(post model.Post) primitive.ObjectID { return post.Token })
+	return result, cursors, nil
+}
+
+func (storage *SQLiteRepository) Subscribe(ctx context.Context, subscriberId model.UserId, targetId model.UserId) error {
+	defer metrics.ObserveSQLiteOperation("subscribe", time.Now())
+
+	if subscriberId == targetId {
+		return model.SelfSubscription
+	}
+
+	result, err := storage.db.ExecContext(ctx,
+		"INSERT INTO subscriptions (subscriber_id, target_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		string(subscriberId), string(targetId))
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return model.AlreadySubscribed
+	}
+
+	return nil
+}
+
+func (storage *SQLiteRepository) GetSubscriptions(ctx context.Context, id model.UserId) ([]model.UserId, error) {
+	defer metrics.ObserveSQLiteOperation("get_subscriptions", time.Now())
+
+	rows, err := storage.db.QueryContext(ctx, "SELECT target_id FROM subscriptions WHERE subscriber_id = $1 ORDER BY position", string(id))
+	if err != nil {
+		return nil, err
+	}
+	return collectSQLiteRows(rows, scanUserId)
+}
+
+func (storage *SQLiteRepository) GetSubscribers(ctx context.Context, id model.UserId) ([]model.UserId, error) {
+	defer metrics.ObserveSQLiteOperation("get_subscribers", time.Now())
+
+	rows, err := storage.db.QueryContext(ctx, "SELECT subscriber_id FROM subscriptions WHERE target_id = $1 ORDER BY position", string(id))
+	if err != nil {
+		return nil, err
+	}
+	return collectSQLiteRows(rows, scanUserId)
+}
+
+func (storage *SQLiteRepository) GetFeed(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.FeedMetadataDocument, model.PageCursors, error) {
+	defer metrics.ObserveSQLiteOperation("get_feed", time.Now())
+
+	query, args, err := keysetQuery("SELECT user_id, token, post_id FROM feeds WHERE user_id = $1", string(id), page)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	rows, err := storage.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+	result, err := collectSQLiteRows(rows, scanSQLiteFeedItem)
+	if err != nil {
+		return nil, model.PageCursors{}, err
+	}
+
+	result, cursors := pageOf(result, page, func(item model.FeedMetadataDocument) primitive.ObjectID { return item.Token })
+	return result, cursors, nil
+}
+
+func (storage *SQLiteRepository) CountFeed(ctx context.Context, id model.UserId, since model.PageToken, limit int) (int, error) {
+	defer metrics.ObserveSQLiteOperation("count_feed", time.Now())
+
+	query := "SELECT count(*) FROM (SELECT 1 FROM feeds WHERE user_id = $1 LIMIT $2) AS unread"
+	args := []any{string(id), limit}
+	if since != model.EmptyPage {
+		token, err := parseCursor(since)
+		if err != nil {
+			return 0, err
+		}
+		query = "SELECT count(*) FROM (SELECT 1 FROM feeds WHERE user_id = $1 AND token > $3 LIMIT $2) AS unread"
+		args = append(args, token[:])
+	}
+
+	var count int
+	err := storage.db.QueryRowContext(ctx, query, args...).Scan(&count)
+	return count, err
+}
+
+func (storage *SQLiteRepository) GetFeedPosts(ctx context.Context, id model.UserId, page model.PageRequest) (model.FeedPage, error) {
+	return hydrateFeed(ctx, storage, id, page)
+}
+
+func (storage *SQLiteRepository) AddPostToFeed(ctx context.Context, post model.FeedMetadataDocument) error {
+	defer metrics.ObserveSQLiteOperation("add_post_to_feed", time.Now())
+
+	_, err := storage.db.ExecContext(ctx, "INSERT INTO feeds (user_id, token, post_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		string(post.UserId), post.Token[:], string(post.PostId))
+
+	return err
+}
+
+func (storage *SQLiteRepository) RemovePostsFromFeed(ctx context.Context, id model.UserId, ids []model.PostId) error {
+	defer metrics.ObserveSQLiteOperation("remove_posts_from_feed", time.Now())
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, string(id))
+	for _, postId := range ids {
+		args = append(args, string(postId))
+	}
+
+	_, err := storage.db.ExecContext(ctx, "DELETE FROM feeds WHERE user_id = $1 AND post_id IN ("+placeholders(2, len(ids))+")", args...)
+	return err
+}
+
+// placeholders lists count numbered parameters starting from $first, sqlite has no arrays to pass them at once
+func placeholders(first, count int) string {
+	params := make([]string, count)
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", first+i)
+	}
+	return strings.Join(params, ", ")
+}
+
+// collectSQLiteRows scans all rows and closes them, the result is empty but not nil if there are no rows
+func collectSQLiteRows[T any](rows *sql.Rows, scan func(row sqliteRow) (T, error)) ([]T, error) {
+	defer rows.Close()
+
+	result := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
+
+func scanSQLitePost(row sqliteRow) (model.Post, error) {
+	var post model.Post
+	var token []byte
+
+	if err := row.Scan(&token, &post.AuthorId, &post.Text, &post.CreatedAt, &post.LastModifiedAt); err != nil {
+		return model.Post{}, err
+	}
+
+	copy(post.Token[:], token)
+	post.Id = model.PostId(post.Token.Hex())
+	return post, nil
+}
+
+func scanSQLiteFeedItem(row sqliteRow) (model.FeedMetadataDocument, error) {
+	var item model.FeedMetadataDocument
+	var token []byte
+
+	if err := row.Scan(&item.UserId, &token, &item.PostId); err != nil {
+		return model.FeedMetadataDocument{}, err
+	}
+
+	copy(item.Token[:], token)
+	return item, nil
+}
+
+func scanUserId(row sqliteRow) (model.UserId, error) {
+	var id model.UserId
+	err := row.Scan(&id)
+	return id, err
+}
@@ -0,0 +1,91 @@
+package repo_test
+
+import (
+	"context"
+	"microblog/internal/config"
+	"microblog/internal/model"
+	"microblog/internal/repo"
+	"microblog/internal/repo/repotest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func newSQLiteRepository(t *testing.T, path string) repo.Repository {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.SQLite.Path = path
+
+	r, err := repo.NewSQLiteRepository(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+
+	return r
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repo.Repository {
+		return newSQLiteRepository(t, filepath.Join(t.TempDir(), "microblog.db"))
+	})
+}
+
+func TestSQLiteRepositoryReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "microblog.db")
+
+	r := newSQLiteRepository(t, path)
+	post, err := r.CreatePost(ctx, "aa", model.Post{Text: "post"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Subscribe(ctx, "bb", "aa"); err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Close()
+
+	// migrations which were applied already are skipped
+	reopened := newSQLiteRepository(t, path)
+	got, err := reopened.GetPostById(ctx, post.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != post {
+		t.Fatalf("got %+v, want %+v", got, post)
+	}
+	if err = reopened.Subscribe(ctx, "bb", "aa"); err != model.AlreadySubscribed {
+		t.Fatalf("got %v, want %v", err, model.AlreadySubscribed)
+	}
+}
+
+func TestSQLiteRepositoryConcurrentWrites(t *testing.T) {
+	r := newSQLiteRepository(t, filepath.Join(t.TempDir(), "microblog.db"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				post, err := r.CreatePost(context.Background(), "aa", model.Post{Text: "post"})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if err = r.AddPostToFeed(context.Background(), model.FeedMetadataDocument{UserId: "bb", PostId: post.Id, Token: post.Token}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	posts := repotest.CollectPosts(t, r, "aa", 7)
+	feed := repotest.CollectFeed(t, r, "bb", 7)
+	if len(posts) != 200 || len(feed) != 200 {
+		t.Fatalf("got %d posts and %d feed items, want 200 of each", len(posts), len(feed))
+	}
+}
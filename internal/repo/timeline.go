@@ -0,0 +1,182 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"microblog/internal/metrics"
+	"microblog/internal/model"
+	"strconv"
+)
+
+// Timelines are redis sorted sets of the newest ids of a list (posts of a user or a feed), scored by the timestamp
+// of the ObjectID. Ids of the same second are ordered by the member itself, so the order matches the ObjectIDs.
+// The marker is added when a timeline is warmed up from the persistent repository: a set without it holds only
+// items added since then and is not served. A warmed up timeline of TimelineLength items may miss older items,
+// pages beyond it are read from the persistent repository and cached under the generation of the list
+const timelineMarker = "-"
+
+// timelineMarkerScore is below scores of all ids, so the marker is never in ranges starting from zero
+const timelineMarkerScore = -1
+
+// addToTimelineScript adds an id and trims the timeline to the length given in ARGV[3], the marker is kept
+var addToTimelineScript = redis.NewScript(`
+local first = 0
+if redis.call('ZSCORE', KEYS[1], ARGV[4]) then
+	first = 1
+end
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[2])
+redis.call('ZREMRANGEBYRANK', KEYS[1], first, -tonumber(ARGV[3]) - 1)
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 0
+`)
+
+func timelineScore(id primitive.ObjectID) string {
+	return strconv.FormatInt(id.Timestamp().Unix(), 10)
+}
+
+func (cache *RedisRepository) addToTimeline(ctx context.Context, key string, id primitive.ObjectID) error {
+	return addToTimelineScript.Run(ctx, cache.client, []string{key},
+		timelineScore(id), id.Hex(), cache.ttl.TimelineLength, timelineMarker, cache.jitter(cache.ttl.PageTTL).Milliseconds()).Err()
+}
+
+// warmUpTimeline loads the newest items, ids added concurrently are merged with them
+func (cache *RedisRepository) warmUpTimeline(ctx context.Context, key string, load func(ctx context.Context, page model.PageRequest) ([]primitive.ObjectID, error)) error {
+	ids, err := load(ctx, model.PageRequest{Cursor: model.EmptyPage, Direction: model.DirectionOlder, Size: cache.ttl.TimelineLength})
+	if err != nil {
+		return err
+	}
+
+	members := make([]*redis.Z, 0, len(ids)+1)
+	members = append(members, &redis.Z{Score: timelineMarkerScore, Member: timelineMarker})
+	for _, id := range ids {
+		score, _ := strconv.ParseFloat(timelineScore(id), 64)
+		members = append(members, &redis.Z{Score: score, Member: id.Hex()})
+	}
+
+	pipe := cache.client.TxPipeline()
+	pipe.ZAdd(ctx, key, members...)
+	pipe.ZRemRangeByRank(ctx, key, 1, -int64(cache.ttl.TimelineLength)-1)
+	pipe.PExpire(ctx, key, cache.jitter(cache.ttl.PageTTL))
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// timelinePage serves the page from the timeline, warming it up if it's missing.
+// ok is false if the page is beyond the cached items or redis fails, then it must be read from the persistent repository
+func (cache *RedisRepository) timelinePage(ctx context.Context, key string, page model.PageRequest,
+	load func(ctx context.Context, page model.PageRequest) ([]primitive.ObjectID, error)) (ids []primitive.ObjectID, cursors model.PageCursors, ok bool, err error) {
+	if page.Cursor != model.EmptyPage {
+		if _, err = parseCursor(page.Cursor); err != nil {
+			return nil, model.PageCursors{}, false, err
+		}
+	}
+	if !cache.available(ctx, key) {
+		return nil, model.PageCursors{}, false, nil
+	}
+
+	ids, warmed, covered, err := cache.readTimeline(ctx, key, page)
+	metrics.ObserveCacheLookup(key, warmed && covered)
+
+	if err == nil && !warmed {
+		// concurrent requests of a missing timeline wait for a single warm up
+		_, err, _ = cache.group.Do(key, func() (interface{}, error) {
+			ctx, cancel := cache.detach(ctx)
+			defer cancel()
+			return nil, cache.warmUpTimeline(ctx, key, load)
+		})
+		if err == nil {
+			ids, warmed, covered, err = cache.readTimeline(ctx, key, page)
+		}
+	}
+	if err != nil {
+		metrics.ObserveCacheBypass(key)
+		return nil, model.PageCursors{}, false, nil
+	}
+	if !warmed || !covered {
+		return nil, model.PageCursors{}, false, nil
+	}
+
+	ids, cursors = pageOf(ids, page, func(id primitive.ObjectID) primitive.ObjectID { return id })
+	return ids, cursors, true, nil
+}
+
+// readTimeline fetches ids of the page with one extra item in the order of storage queries.
+// Ids of the cursor's second are fetched separately, score ranges can't exclude the cursor itself
+func (cache *RedisRepository) readTimeline(ctx context.Context, key string, page model.PageRequest) (ids []primitive.ObjectID, warmed, covered bool, err error) {
+	limit := int64(page.Size + 1)
+	newer := newerPage(page)
+
+	pipe := cache.client.Pipeline()
+	marker := pipe.ZScore(ctx, key, timelineMarker)
+	length := pipe.ZCard(ctx, key)
+	oldest := pipe.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: "0", Max: "+inf", Count: 1})
+
+	var cursor string
+	var sameSecond, rest *redis.StringSliceCmd
+	if page.Cursor == model.EmptyPage {
+		rest = pipe.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{Min: "0", Max: "+inf", Count: limit})
+	} else {
+		token, err := parseCursor(page.Cursor)
+		if err != nil {
+			return nil, false, false, err
+		}
+		cursor = token.Hex()
+		score := timelineScore(token)
+
+		if newer {
+			sameSecond = pipe.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: score, Max: score})
+			rest = pipe.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: "(" + score, Max: "+inf", Count: limit})
+		} else {
+			sameSecond = pipe.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{Min: score, Max: score})
+			rest = pipe.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{Min: "0", Max: "(" + score, Count: limit})
+		}
+	}
+
+	// the marker is missing in sets which are not warmed up, its lookup fails with redis.Nil
+	if _, err = pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, false, false, fmt.Errorf("failed to get value from redis due to error %s", err)
+	}
+	if marker.Err() == redis.Nil {
+		return nil, false, false, nil
+	}
+
+	var members []string
+	if sameSecond != nil {
+		for _, member := range sameSecond.Val() {
+			if (newer && member > cursor) || (!newer && member < cursor) {
+				members = append(members, member)
+			}
+		}
+	}
+	members = append(members, rest.Val()...)
+	if int64(len(members)) > limit {
+		members = members[:limit]
+	}
+
+	// a full timeline may miss older items, so it covers the page only if the page ends within it
+	full := length.Val()-1 >= int64(cache.ttl.TimelineLength)
+	switch {
+	case !full:
+		covered = true
+	case newer:
+		covered = len(oldest.Val()) > 0 && cursor >= oldest.Val()[0]
+	default:
+		covered = int64(len(members)) == limit
+	}
+	if !covered {
+		return nil, true, false, nil
+	}
+
+	ids = make([]primitive.ObjectID, 0, len(members))
+	for _, member := range members {
+		id, err := primitive.ObjectIDFromHex(member)
+		if err != nil {
+			return nil, false, false, fmt.Errorf("unexpected timeline member %q", member)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, true, true, nil
+}
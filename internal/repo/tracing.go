@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"microblog/internal/model"
+	"microblog/internal/tracing"
+)
+
+var _ Repository = (*TracingRepository)(nil)
+
+// TracingRepository wraps every call of the underlying repository with a span named "repo.<system>.<method>"
+type TracingRepository struct {
+	repo   Repository
+	system string
+}
+
+func NewTracingRepository(system string, repo Repository) Repository {
+	return &TracingRepository{repo: repo, system: system}
+}
+
+func (t *TracingRepository) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("repo.system", t.system))
+	return tracing.Tracer().Start(ctx, "repo."+t.system+"."+method, trace.WithAttributes(attrs...))
+}
+
+func (t *TracingRepository) CreatePost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error) {
+	ctx, span := t.start(ctx, "CreatePost", attribute.String("user.id", string(id)))
+	result, err := t.repo.CreatePost(ctx, id, post)
+	span.SetAttributes(attribute.String("post.id", string(result.Id)))
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *TracingRepository) EditPost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error) {
+	ctx, span := t.start(ctx, "EditPost", attribute.String("user.id", string(id)), attribute.String("post.id", string(post.Id)))
+	result, err := t.repo.EditPost(ctx, id, post)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *TracingRepository) GetPostById(ctx context.Context, id model.PostId) (model.Post, error) {
+	ctx, span := t.start(ctx, "GetPostById", attribute.String("post.id", string(id)))
+	result, err := t.repo.GetPostById(ctx, id)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *TracingRepository) GetPostsByIds(ctx context.Context, ids []model.PostId) ([]model.Post, error) {
+	ctx, span := t.start(ctx, "GetPostsByIds", attribute.Int("posts.requested", len(ids)))
+	result, err := t.repo.GetPostsByIds(ctx, ids)
+	span.SetAttributes(attribute.Int("posts.length", len(result)))
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *TracingRepository) GetPosts(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.Post, model.PageCursors, error) {
+	ctx, span := t.start(ctx, "GetPosts",
+		attribute.String("user.id", string(id)),
+		attribute.Bool("page.first", page.Cursor == model.EmptyPage),
+		attribute.Bool("page.newer", page.Direction == model.DirectionNewer),
+		attribute.Int("page.size", page.Size))
+	result, cursors, err := t.repo.GetPosts(ctx, id, page)
+	span.SetAttributes(attribute.Int("page.length", len(result)))
+	tracing.End(span, err)
+	return result, cursors, err
+}
+
+func (t *TracingRepository) Subscribe(ctx context.Context, from model.UserId, to model.UserId) error {
+	ctx, span := t.start(ctx, "Subscribe", attribute.String("user.id", string(from)), attribute.String("target.id", string(to)))
+	err := t.repo.Subscribe(ctx, from, to)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracingRepository) GetSubscriptions(ctx context.Context, id model.UserId) ([]model.UserId, error) {
+	ctx, span := t.start(ctx, "GetSubscriptions", attribute.String("user.id", string(id)))
+	result, err := t.repo.GetSubscriptions(ctx, id)
+	span.SetAttributes(attribute.Int("users.length", len(result)))
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *TracingRepository) GetSubscribers(ctx context.Context, id model.UserId) ([]model.UserId, error) {
+	ctx, span := t.start(ctx, "GetSubscribers", attribute.String("user.id", string(id)))
+	result, err := t.repo.GetSubscribers(ctx, id)
+	span.SetAttributes(attribute.Int("users.length", len(result)))
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *TracingRepository) GetFeed(ctx context.Context, id model.UserId, page model.PageRequest) ([]model.FeedMetadataDocument, model.PageCursors, error) {
+	ctx, span := t.start(ctx, "GetFeed",
+		attribute.String("user.id", string(id)),
+		attribute.Bool("page.first", page.Cursor == model.EmptyPage),
+		attribute.Bool("page.newer", page.Direction == model.DirectionNewer),
+		attribute.Int("page.size", page.Size))
+	result, cursors, err := t.repo.GetFeed(ctx, id, page)
+	span.SetAttributes(attribute.Int("page.length", len(result)))
+	tracing.End(span, err)
+	return result, cursors, err
+}
+
+func (t *TracingRepository) CountFeed(ctx context.Context, id model.UserId, since model.PageToken, limit int) (int, error) {
+	ctx, span := t.start(ctx, "CountFeed", attribute.String("user.id", string(id)), attribute.Bool("since.set", since != model.EmptyPage))
+	result, err := t.repo.CountFeed(ctx, id, since, limit)
+	span.SetAttributes(attribute.Int("feed.count", result))
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *TracingRepository) GetFeedPosts(ctx context.Context, id model.UserId, page model.PageRequest) (model.FeedPage, error) {
+	ctx, span := t.start(ctx, "GetFeedPosts",
+		attribute.String("user.id", string(id)),
+		attribute.Bool("page.first", page.Cursor == model.EmptyPage),
+		attribute.Bool("page.newer", page.Direction == model.DirectionNewer),
+		attribute.Int("page.size", page.Size))
+	result, err := t.repo.GetFeedPosts(ctx, id, page)
+	span.SetAttributes(attribute.Int("page.length", len(result.Posts)), attribute.Int("page.dangling", len(result.Dangling)))
+	tracing.End(span, err)
+	return result, err
+}
+
+func (t *TracingRepository) AddPostToFeed(ctx context.Context, post model.FeedMetadataDocument) error {
+	ctx, span := t.start(ctx, "AddPostToFeed", attribute.String("user.id", string(post.UserId)), attribute.String("post.id", string(post.PostId)))
+	err := t.repo.AddPostToFeed(ctx, post)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracingRepository) RemovePostsFromFeed(ctx context.Context, id model.UserId, ids []model.PostId) error {
+	ctx, span := t.start(ctx, "RemovePostsFromFeed", attribute.String("user.id", string(id)), attribute.Int("posts.length", len(ids)))
+	err := t.repo.RemovePostsFromFeed(ctx, id, ids)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *TracingRepository) HealthChecks() []HealthCheck {
+	return t.repo.HealthChecks()
+}
+
+func (t *TracingRepository) Close() error {
+	return t.repo.Close()
+}
@@ -0,0 +1,183 @@
+package repo
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"log/slog"
+	"microblog/internal/metrics"
+	"microblog/internal/model"
+	"microblog/internal/utils"
+	"slices"
+	"strings"
+	"time"
+)
+
+// User lists (subscribers and subscriptions of a user) are redis sorted sets scored by the position in the list.
+// They are updated by Subscribe after the persistent repository, and the marker is added when a list is warmed up:
+// a set without it holds only users added since then and is not served. Warm up merges loaded users with them,
+// so a subscription made during a warm up is never lost. User ids are hex, so the marker is never a member
+const userListMarker = "-"
+
+// reconcileBatchSize is the number of keys requested by a single SCAN of the reconciliation
+const reconcileBatchSize = 100
+
+// addToUserListScript appends a user after all users of the list. Loaded users are scored below zero and the marker
+// is scored zero, so appended users stay after them when the list is warmed up later
+var addToUserListScript = redis.NewScript(`
+local score = 1
+local last = redis.call('ZREVRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if last[2] and tonumber(last[2]) >= 1 then
+	score = tonumber(last[2]) + 1
+end
+redis.call('ZADD', KEYS[1], 'NX', score, ARGV[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// addToUserList appends a user to the list, if it fails the list is dropped before the cache is read again
+func (cache *RedisRepository) addToUserList(ctx context.Context, key string, id model.UserId) {
+	err := addToUserListScript.Run(ctx, cache.client, []string{key}, string(id), cache.jitter(cache.ttl.SubscriptionsTTL).Milliseconds()).Err()
+	if err != nil {
+		cache.invalidations.add(key)
+	}
+}
+
+// userList serves the list from redis, warming it up if it's missing. If redis fails, the list is loaded directly
+func (cache *RedisRepository) userList(ctx context.Context, key string, load func(ctx context.Context) ([]model.UserId, error)) ([]model.UserId, error) {
+	if !cache.available(ctx, key) {
+		return load(ctx)
+	}
+
+	users, warmed, err := cache.readUserList(ctx, key)
+	if err == nil {
+		metrics.ObserveCacheLookup(key, warmed)
+	}
+
+	if err == nil && !warmed {
+		// concurrent requests of a missing list wait for a single warm up
+		_, err, _ = cache.group.Do(key, func() (interface{}, error) {
+			ctx, cancel := cache.detach(ctx)
+			defer cancel()
+			return nil, cache.warmUpUserList(ctx, key, load)
+		})
+		if err == nil {
+			users, warmed, err = cache.readUserList(ctx, key)
+		}
+	}
+	if err != nil || !warmed {
+		metrics.ObserveCacheBypass(key)
+		return load(ctx)
+	}
+
+	return users, nil
+}
+
+func (cache *RedisRepository) readUserList(ctx context.Context, key string) (users []model.UserId, warmed bool, err error) {
+	pipe := cache.client.Pipeline()
+	marker := pipe.ZScore(ctx, key, userListMarker)
+	members := pipe.ZRange(ctx, key, 0, -1)
+
+	// the marker is missing in sets which are not warmed up, its lookup fails with redis.Nil
+	if _, err = pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, false, err
+	}
+	if marker.Err() == redis.Nil {
+		return nil, false, nil
+	}
+
+	users = make([]model.UserId, 0, len(members.Val())-1)
+	for _, member := range members.Val() {
+		if member != userListMarker {
+			users = append(users, model.UserId(member))
+		}
+	}
+	return users, true, nil
+}
+
+func (cache *RedisRepository) warmUpUserList(ctx context.Context, key string, load func(ctx context.Context) ([]model.UserId, error)) error {
+	users, err := load(ctx)
+	if err != nil {
+		return err
+	}
+
+	members := make([]*redis.Z, 0, len(users)+1)
+	members = append(members, &redis.Z{Score: 0, Member: userListMarker})
+	for i, id := range users {
+		members = append(members, &redis.Z{Score: float64(i - len(users)), Member: string(id)})
+	}
+
+	pipe := cache.client.TxPipeline()
+	pipe.ZAdd(ctx, key, members...)
+	pipe.PExpire(ctx, key, cache.jitter(cache.ttl.SubscriptionsTTL))
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// reconcile compares cached user lists with the persistent repository every interval until the context is done
+func (cache *RedisRepository) reconcile(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		dropped, err := cache.reconcileUserLists(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			slog.ErrorContext(ctx, "Failed to reconcile cached user lists", slog.Any("error", err))
+		case dropped > 0:
+			slog.InfoContext(ctx, "Reconciled cached user lists", slog.Int("dropped", dropped))
+		}
+	}
+}
+
+// reconcileUserLists drops cached user lists which differ from the persistent repository, they are warmed up again
+// by the next read. Dropping a list is always safe, so lists changed during the comparison may be dropped needlessly
+func (cache *RedisRepository) reconcileUserLists(ctx context.Context) (int, error) {
+	families := []struct {
+		prefix string
+		load   func(ctx context.Context, id model.UserId) ([]model.UserId, error)
+	}{
+		{prefix: utils.CreateRedisKeyForSubscribers(""), load: cache.persistentRepo.GetSubscribers},
+		{prefix: utils.CreateRedisKeyForSubscriptions(""), load: cache.persistentRepo.GetSubscriptions},
+	}
+
+	dropped := 0
+	for _, family := range families {
+		iter := cache.client.Scan(ctx, 0, family.prefix+"*", reconcileBatchSize).Iterator()
+		for iter.Next(ctx) {
+			key := iter.Val()
+			cached, warmed, err := cache.readUserList(ctx, key)
+			if err != nil {
+				return dropped, err
+			}
+			if !warmed {
+				continue
+			}
+
+			stored, err := family.load(ctx, model.UserId(strings.TrimPrefix(key, family.prefix)))
+			if err != nil {
+				return dropped, err
+			}
+			if slices.Equal(cached, stored) {
+				continue
+			}
+
+			slog.WarnContext(ctx, "Cached user list differs from the persistent repository", slog.String("key", key))
+			metrics.ObserveCacheRepair(key)
+			cache.invalidate(ctx, key)
+			dropped++
+		}
+		if err := iter.Err(); err != nil {
+			return dropped, err
+		}
+	}
+
+	return dropped, nil
+}
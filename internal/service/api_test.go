@@ -0,0 +1,660 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log/slog"
+	"microblog/internal/breaker"
+	"microblog/internal/config"
+	"microblog/internal/logging"
+	"microblog/internal/model"
+	"microblog/internal/ratelimit"
+	"microblog/internal/repo"
+	"microblog/internal/repo/repotest"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCreateEditFetchPost(t *testing.T) {
+	h := newHarness(t)
+
+	created := h.createPost("aa", "hello")
+	if created.Id == "" || created.AuthorId != "aa" || created.Text != "hello" || created.CreatedAt == "" {
+		t.Fatalf("unexpected created post %+v", created)
+	}
+
+	var edited model.Post
+	h.do(http.MethodPatch, "/api/v1/posts/"+string(created.Id), "aa", map[string]string{"text": "edited"}).
+		decode(t, http.StatusOK, &edited)
+	if edited.Id != created.Id || edited.Text != "edited" || edited.CreatedAt != created.CreatedAt {
+		t.Fatalf("unexpected edited post %+v", edited)
+	}
+
+	var fetched model.Post
+	h.do(http.MethodGet, "/api/v1/posts/"+string(created.Id), "", nil).decode(t, http.StatusOK, &fetched)
+	if fetched != edited {
+		t.Fatalf("fetched %+v, want %+v", fetched, edited)
+	}
+
+	if code := h.do(http.MethodPatch, "/api/v1/posts/"+string(created.Id), "bb", map[string]string{"text": "stolen"}).
+		errorCode(t, http.StatusForbidden); code != model.NotPostAuthor.Error() {
+		t.Fatalf("got code %q for edit by another user", code)
+	}
+	if code := h.do(http.MethodPatch, "/api/v1/posts/unknown", "aa", map[string]string{"text": "edited"}).
+		errorCode(t, http.StatusNotFound); code != model.PostNotFound.Error() {
+		t.Fatalf("got code %q for edit of unknown post", code)
+	}
+	if code := h.do(http.MethodGet, "/api/v1/posts/unknown", "", nil).
+		errorCode(t, http.StatusNotFound); code != model.PostNotFound.Error() {
+		t.Fatalf("got code %q for unknown post", code)
+	}
+}
+
+func TestCreatePostValidation(t *testing.T) {
+	h := newHarness(t)
+
+	for _, tc := range []struct {
+		name  string
+		user  model.UserId
+		body  any
+		want  int
+		code  string
+		field string
+	}{
+		{name: "NoUser", body: map[string]string{"text": "hello"}, want: http.StatusUnauthorized, code: model.InvalidUserId.Error()},
+		{name: "Malformed", user: "aa", body: `{"text":`, want: http.StatusBadRequest, code: model.InvalidRequestBody.Error()},
+		{name: "Empty", user: "aa", body: map[string]string{"text": "  "}, want: http.StatusBadRequest, code: model.InvalidRequestBody.Error(), field: "text"},
+		{name: "TooLong", user: "aa", body: map[string]string{"text": strings.Repeat("a", 281)}, want: http.StatusBadRequest, code: model.InvalidRequestBody.Error(), field: "text"},
+		{name: "UnknownField", user: "aa", body: map[string]string{"text": "hello", "likes": "1"}, want: http.StatusBadRequest, code: model.InvalidRequestBody.Error(), field: "likes"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var body ErrorResponse
+			h.do(http.MethodPost, "/api/v1/posts", tc.user, tc.body).decode(t, tc.want, &body)
+
+			if body.Error.Code != tc.code {
+				t.Fatalf("got code %q, want %q", body.Error.Code, tc.code)
+			}
+			if tc.field != "" && (len(body.Error.Fields) != 1 || body.Error.Fields[0].Field != tc.field) {
+				t.Fatalf("got fields %+v, want a single error of %q", body.Error.Fields, tc.field)
+			}
+		})
+	}
+
+	if posts := h.collectPages("/api/v1/users/aa/posts?size=10", ""); len(posts) != 0 {
+		t.Fatalf("invalid requests created %d posts", len(posts))
+	}
+}
+
+func TestPaginateUserPosts(t *testing.T) {
+	h := newHarness(t)
+
+	var want []model.Post
+	for i := 0; i < 5; i++ {
+		want = append([]model.Post{h.createPost("aa", "post")}, want...)
+	}
+	h.createPost("bb", "other")
+
+	for _, size := range []int{1, 2, 5, 10} {
+		got := h.collectPages("/api/v1/users/aa/posts?size="+strconv.Itoa(size), "")
+		if len(got) != len(want) {
+			t.Fatalf("size %d: got %d posts, want %d", size, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].Id != want[i].Id {
+				t.Fatalf("size %d: post %d is %s, want %s", size, i, got[i].Id, want[i].Id)
+			}
+		}
+	}
+
+	var page GetPostPageResponse
+	h.do(http.MethodGet, "/api/v1/users/bb/posts?size=1", "", nil).decode(t, http.StatusOK, &page)
+	if page.NextPage != nil {
+		t.Fatalf("unexpected next page %q for a single post", *page.NextPage)
+	}
+
+	h.do(http.MethodGet, "/api/v1/users/aa/posts?size=1", "", nil).decode(t, http.StatusOK, &page)
+	if page.NextPage == nil {
+		t.Fatal("no next page")
+	}
+	if code := h.do(http.MethodGet, "/api/v1/users/bb/posts?page="+string(*page.NextPage), "", nil).
+		errorCode(t, http.StatusBadRequest); code != model.InvalidPageToken.Error() {
+		t.Fatalf("got code %q for a token of another user", code)
+	}
+
+	for _, size := range []string{"0", "-1", "101", "ten"} {
+		if code := h.do(http.MethodGet, "/api/v1/users/aa/posts?size="+size, "", nil).
+			errorCode(t, http.StatusBadRequest); code != model.InvalidPageSize.Error() {
+			t.Fatalf("got code %q for size %s", code, size)
+		}
+	}
+}
+
+func TestSubscribeBackfillsFeed(t *testing.T) {
+	h := newHarness(t)
+
+	var old []model.Post
+	for i := 0; i < 3; i++ {
+		old = append([]model.Post{h.createPost("aa", "before")}, old...)
+	}
+	h.runTasks()
+
+	h.subscribe("bb", "aa")
+	if feed := h.collectPages("/api/v1/feed?size=2", "bb"); len(feed) != 0 {
+		t.Fatalf("feed has %d posts before the consumer ran", len(feed))
+	}
+
+	h.runTasks()
+	feed := h.collectPages("/api/v1/feed?size=2", "bb")
+	if len(feed) != len(old) {
+		t.Fatalf("got %d posts in feed, want %d", len(feed), len(old))
+	}
+	for i := range old {
+		if feed[i] != old[i] {
+			t.Fatalf("feed post %d is %+v, want %+v", i, feed[i], old[i])
+		}
+	}
+
+	fresh := h.createPost("aa", "after")
+	h.runTasks()
+
+	feed = h.collectPages("/api/v1/feed?size=2", "bb")
+	if len(feed) != len(old)+1 || feed[0] != fresh {
+		t.Fatalf("new post is not at the top of the feed: %+v", feed)
+	}
+
+	if feed := h.collectPages("/api/v1/feed?size=2", "cc"); len(feed) != 0 {
+		t.Fatalf("feed of a user without subscriptions has %d posts", len(feed))
+	}
+}
+
+func TestPageTokens(t *testing.T) {
+	secret := withConfig(func(cfg *config.Config) { cfg.Pagination.TokenSecret = "0123456789abcdef" })
+	h := newHarness(t, secret)
+
+	for i := 0; i < 3; i++ {
+		h.createPost("aa", "post")
+	}
+	h.subscribe("bb", "aa")
+	h.runTasks()
+
+	var posts, feed GetPostPageResponse
+	h.do(http.MethodGet, "/api/v1/users/aa/posts?size=1", "", nil).decode(t, http.StatusOK, &posts)
+	h.do(http.MethodGet, "/api/v1/feed?size=1", "bb", nil).decode(t, http.StatusOK, &feed)
+	if posts.NextPage == nil || feed.NextPage == nil {
+		t.Fatal("no next page")
+	}
+
+	for _, tc := range []struct {
+		name string
+		path string
+		user model.UserId
+	}{
+		{name: "FeedOfOtherUser", path: "/api/v1/feed?page=" + string(*feed.NextPage), user: "cc"},
+		{name: "FeedTokenForPosts", path: "/api/v1/users/bb/posts?page=" + string(*feed.NextPage)},
+		{name: "PostsTokenForFeed", path: "/api/v1/feed?page=" + string(*posts.NextPage), user: "aa"},
+		{name: "RawCursor", path: "/api/v1/users/aa/posts?page=" + string(posts.Posts[0].Id)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := h.do(http.MethodGet, tc.path, tc.user, nil).
+				errorCode(t, http.StatusBadRequest); code != model.InvalidPageToken.Error() {
+				t.Fatalf("got code %q", code)
+			}
+		})
+	}
+
+	// another server with the same secret accepts the token
+	other := newHarness(t, secret, withRepository(h.repo))
+	var next GetPostPageResponse
+	other.do(http.MethodGet, "/api/v1/feed?size=1&page="+string(*feed.NextPage), "bb", nil).decode(t, http.StatusOK, &next)
+	if len(next.Posts) != 1 || next.Posts[0] == feed.Posts[0] {
+		t.Fatalf("got %+v after %+v", next.Posts, feed.Posts)
+	}
+}
+
+func TestPollFeed(t *testing.T) {
+	h := newHarness(t, withConfig(func(cfg *config.Config) { cfg.Pagination.MaxUnreadCount = 3 }))
+
+	var old []model.Post
+	for i := 0; i < 3; i++ {
+		old = append([]model.Post{h.createPost("aa", "old")}, old...)
+	}
+	h.runTasks()
+	h.subscribe("bb", "aa")
+	h.runTasks()
+
+	var first GetPostPageResponse
+	h.do(http.MethodGet, "/api/v1/feed?size=2", "bb", nil).decode(t, http.StatusOK, &first)
+	if first.NextPage == nil || first.PrevPage == nil {
+		t.Fatalf("got next page %v and prev page %v", first.NextPage, first.PrevPage)
+	}
+
+	unread := func(query string) int {
+		var body UnreadCountResponse
+		h.do(http.MethodGet, "/api/v1/feed/unread-count"+query, "bb", nil).decode(t, http.StatusOK, &body)
+		return body.Count
+	}
+	if count := unread("?since=" + string(old[0].Id)); count != 0 {
+		t.Fatalf("got %d unread posts before new posts", count)
+	}
+
+	var fresh []model.Post
+	for i := 0; i < 2; i++ {
+		fresh = append([]model.Post{h.createPost("aa", "fresh")}, fresh...)
+	}
+	h.runTasks()
+
+	if count := unread("?since=" + string(old[0].Id)); count != len(fresh) {
+		t.Fatalf("got %d unread posts, want %d", count, len(fresh))
+	}
+	if count := unread(""); count != 3 {
+		t.Fatalf("got %d unread posts without since, want the cap 3", count)
+	}
+
+	var newer GetPostPageResponse
+	h.do(http.MethodGet, "/api/v1/feed?page="+string(*first.PrevPage), "bb", nil).decode(t, http.StatusOK, &newer)
+	if len(newer.Posts) != len(fresh) || newer.Posts[0] != fresh[0] || newer.Posts[1] != fresh[1] || newer.PrevPage == nil {
+		t.Fatalf("got %+v, want %+v", newer, fresh)
+	}
+
+	var head GetPostPageResponse
+	h.do(http.MethodGet, "/api/v1/feed?page="+string(*newer.PrevPage), "bb", nil).decode(t, http.StatusOK, &head)
+	if len(head.Posts) != 0 || head.PrevPage == nil || head.NextPage != nil {
+		t.Fatalf("got %+v at the head of the feed", head)
+	}
+
+	var since GetPostPageResponse
+	h.do(http.MethodGet, "/api/v1/feed?size=3&since="+string(old[2].Id), "bb", nil).decode(t, http.StatusOK, &since)
+	if len(since.Posts) != 3 || since.Posts[0] != fresh[1] || since.Posts[2] != old[1] {
+		t.Fatalf("got %+v since the oldest post", since.Posts)
+	}
+
+	var before GetPostPageResponse
+	h.do(http.MethodGet, "/api/v1/users/aa/posts?before="+string(fresh[1].Id), "", nil).decode(t, http.StatusOK, &before)
+	if len(before.Posts) != len(old) || before.Posts[0] != old[0] || before.NextPage != nil {
+		t.Fatalf("got %+v before the first fresh post", before)
+	}
+
+	for _, path := range []string{
+		"/api/v1/feed?since=" + string(old[0].Id) + "&before=" + string(old[1].Id),
+		"/api/v1/feed?since=" + string(old[0].Id) + "&page=" + string(*first.NextPage),
+		"/api/v1/feed?since=zz",
+		"/api/v1/feed/unread-count?since=zz",
+	} {
+		if code := h.do(http.MethodGet, path, "bb", nil).errorCode(t, http.StatusBadRequest); code != model.InvalidPageCursor.Error() {
+			t.Fatalf("got code %q for %s", code, path)
+		}
+	}
+}
+
+func TestDanglingFeedItems(t *testing.T) {
+	storage := repo.NewMemoryRepository()
+	h := newHarness(t, withRepository(storage))
+
+	post := h.createPost("aa", "kept")
+	h.runTasks()
+	h.subscribe("bb", "aa")
+	h.runTasks()
+
+	// feed item of a post which no longer exists
+	token := primitive.NewObjectID()
+	missing := model.FeedMetadataDocument{UserId: "bb", PostId: model.PostId(token.Hex()), Token: token}
+	if err := storage.AddPostToFeed(context.Background(), missing); err != nil {
+		t.Fatal(err)
+	}
+
+	var page GetPostPageResponse
+	h.do(http.MethodGet, "/api/v1/feed", "bb", nil).decode(t, http.StatusOK, &page)
+	if len(page.Posts) != 1 || page.Posts[0].Id != post.Id {
+		t.Fatalf("got posts %+v, want only %s", page.Posts, post.Id)
+	}
+
+	producer := h.producer.(*queuedProducer)
+	if pending := producer.Pending(); pending != 1 {
+		t.Fatalf("got %d pending tasks, want cleanup task", pending)
+	}
+	h.runTasks()
+
+	items, _, err := storage.GetFeed(context.Background(), "bb", repotest.OlderPage(model.EmptyPage, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].PostId != post.Id {
+		t.Fatalf("got feed %+v after cleanup", items)
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	h := newHarness(t)
+	producer := h.producer.(*queuedProducer)
+
+	h.subscribe("aa", "bb")
+	h.subscribe("aa", "cc")
+	h.subscribe("dd", "bb")
+	if pending := producer.Pending(); pending != 3 {
+		t.Fatalf("got %d pending tasks, want 3", pending)
+	}
+
+	// re-subscribing succeeds without rebuilding the feed once more
+	h.subscribe("aa", "bb")
+	if pending := producer.Pending(); pending != 3 {
+		t.Fatalf("re-subscribing sent a task, %d pending", pending)
+	}
+
+	var users GetUsersResponse
+	h.do(http.MethodGet, "/api/v1/subscriptions", "aa", nil).decode(t, http.StatusOK, &users)
+	if strings.Join(userIds(users.Users), ",") != "bb,cc" {
+		t.Fatalf("got subscriptions %v", users.Users)
+	}
+
+	h.do(http.MethodGet, "/api/v1/subscribers", "bb", nil).decode(t, http.StatusOK, &users)
+	if strings.Join(userIds(users.Users), ",") != "aa,dd" {
+		t.Fatalf("got subscribers %v", users.Users)
+	}
+
+	h.do(http.MethodGet, "/api/v1/subscribers", "aa", nil).decode(t, http.StatusOK, &users)
+	if users.Users == nil || len(users.Users) != 0 {
+		t.Fatalf("got subscribers %v, want an empty list", users.Users)
+	}
+
+	if code := h.do(http.MethodPost, "/api/v1/users/aa/subscribe", "aa", nil).
+		errorCode(t, http.StatusBadRequest); code != model.SelfSubscription.Error() {
+		t.Fatalf("got code %q for self subscription", code)
+	}
+}
+
+func TestIdempotentPostCreation(t *testing.T) {
+	h := newHarness(t)
+
+	first := h.do(http.MethodPost, "/api/v1/posts", "aa", map[string]string{"text": "hello"}, idempotencyKeyHeader, "key-1")
+	second := h.do(http.MethodPost, "/api/v1/posts", "aa", map[string]string{"text": "hello"}, idempotencyKeyHeader, "key-1")
+
+	var a, b model.Post
+	first.decode(t, http.StatusOK, &a)
+	second.decode(t, http.StatusOK, &b)
+	if a != b || second.header.Get(idempotentReplayedHeader) != "true" {
+		t.Fatalf("second response is not a replay: %+v and %+v", a, b)
+	}
+
+	if code := h.do(http.MethodPost, "/api/v1/posts", "aa", map[string]string{"text": "other"}, idempotencyKeyHeader, "key-1").
+		errorCode(t, http.StatusConflict); code != model.IdempotencyKeyReused.Error() {
+		t.Fatalf("got code %q for a reused key", code)
+	}
+
+	if posts := h.collectPages("/api/v1/users/aa/posts?size=10", ""); len(posts) != 1 {
+		t.Fatalf("got %d posts, want 1", len(posts))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	h := newHarness(t, withConfig(func(cfg *config.Config) {
+		cfg.RateLimit.Enabled = true
+		cfg.RateLimit.WriteBurst = 2
+	}))
+
+	h.createPost("aa", "one")
+	h.createPost("aa", "two")
+
+	resp := h.do(http.MethodPost, "/api/v1/posts", "aa", map[string]string{"text": "three"})
+	if code := resp.errorCode(t, http.StatusTooManyRequests); code != model.RateLimitExceeded.Error() {
+		t.Fatalf("got code %q", code)
+	}
+	if resp.header.Get("Retry-After") == "" {
+		t.Fatal("no Retry-After header")
+	}
+
+	// buckets are per user
+	h.createPost("bb", "one")
+}
+
+func TestFailures(t *testing.T) {
+	t.Run("Producer", func(t *testing.T) {
+		h := newHarness(t, withProducer(failingProducer{err: errors.New("broker is down")}))
+
+		if code := h.do(http.MethodPost, "/api/v1/posts", "aa", map[string]string{"text": "hello"}).
+			errorCode(t, http.StatusInternalServerError); code != internalErrorCode {
+			t.Fatalf("got code %q", code)
+		}
+	})
+
+	t.Run("Repository", func(t *testing.T) {
+		h := newHarness(t, withRepository(failingRepository{
+			Repository: repo.NewMemoryRepository(),
+			createPost: model.PostCreationFailed,
+			getFeed:    errors.New("connection reset"),
+		}))
+
+		if code := h.do(http.MethodPost, "/api/v1/posts", "aa", map[string]string{"text": "hello"}).
+			errorCode(t, http.StatusInternalServerError); code != model.PostCreationFailed.Error() {
+			t.Fatalf("got code %q", code)
+		}
+
+		resp := h.do(http.MethodGet, "/api/v1/feed", "aa", nil)
+		if code := resp.errorCode(t, http.StatusInternalServerError); code != internalErrorCode {
+			t.Fatalf("got code %q", code)
+		}
+		if strings.Contains(string(resp.body), "connection reset") {
+			t.Fatalf("internal error details leaked: %s", resp.body)
+		}
+	})
+
+	// requests are let through without rate limits, and redis is not called while the breaker is open
+	t.Run("RedisOutage", func(t *testing.T) {
+		server := miniredis.RunT(t)
+
+		cfg := config.Default()
+		cfg.Redis.Addr = server.Addr()
+		cfg.Redis.BreakerThreshold = 2
+		cfg.Redis.BreakerCooldown = time.Hour
+		client := newRedisClient(cfg)
+		t.Cleanup(func() { _ = client.Close() })
+
+		h := newHarness(t,
+			withLimiter(ratelimit.NewRedisLimiter(client)),
+			withConfig(func(cfg *config.Config) { cfg.RateLimit.Enabled = true }))
+
+		resp := h.do(http.MethodGet, "/api/v1/users/aa/posts", "bb", nil)
+		resp.decode(t, http.StatusOK, nil)
+		if resp.header.Get("RateLimit-Limit") == "" {
+			t.Fatal("request is not rate limited while redis is up")
+		}
+
+		server.Close()
+		for i := 0; i < 3; i++ {
+			resp = h.do(http.MethodGet, "/api/v1/users/aa/posts", "bb", nil)
+			resp.decode(t, http.StatusOK, nil)
+			if resp.header.Get("RateLimit-Limit") != "" {
+				t.Fatal("request is rate limited while redis is down")
+			}
+		}
+
+		if err := client.Ping(context.Background()).Err(); !errors.Is(err, breaker.ErrOpen) {
+			t.Fatalf("got %v, want open breaker", err)
+		}
+	})
+}
+
+func TestRouting(t *testing.T) {
+	h := newHarness(t)
+
+	if code := h.do(http.MethodGet, "/api/v1/unknown", "", nil).errorCode(t, http.StatusNotFound); code != model.RouteNotFound.Error() {
+		t.Fatalf("got code %q", code)
+	}
+	if code := h.do(http.MethodDelete, "/api/v1/posts", "aa", nil).errorCode(t, http.StatusMethodNotAllowed); code != model.MethodNotAllowed.Error() {
+		t.Fatalf("got code %q", code)
+	}
+
+	h.do(http.MethodGet, "/maintenance/ping", "", nil).decode(t, http.StatusOK, nil)
+	h.do(http.MethodGet, "/maintenance/live", "", nil).decode(t, http.StatusOK, nil)
+
+	var ready ReadinessResponse
+	h.do(http.MethodGet, "/maintenance/ready", "", nil).decode(t, http.StatusOK, &ready)
+	if ready.Status != "up" {
+		t.Fatalf("got readiness %+v", ready)
+	}
+
+	resp := h.do(http.MethodGet, "/api/v1/posts/unknown", "", nil, requestIdHeader, "given-id")
+	if resp.header.Get(requestIdHeader) != "given-id" {
+		t.Fatalf("request id is not propagated: %q", resp.header.Get(requestIdHeader))
+	}
+}
+
+// lockedBuffer collects logs written by handlers of the test server while the test reads them
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// hasRecord reports if a JSON record with the message and request id was logged
+func (b *lockedBuffer) hasRecord(message, requestId string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	for scanner.Scan() {
+		var record struct {
+			Message   string `json:"msg"`
+			RequestId string `json:"request_id"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &record) == nil && record.Message == message && record.RequestId == requestId {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRequestIdLogging(t *testing.T) {
+	logs := &lockedBuffer{}
+	logger, err := logging.New(config.LoggingConfig{Level: "debug", Format: config.LogFormatJSON}, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	h := newHarness(t)
+
+	for _, tc := range []struct {
+		name   string
+		given  string
+		reused bool
+	}{
+		{name: "Given", given: "given-id", reused: true},
+		{name: "Invalid", given: "not an id"},
+		{name: "Missing"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := h.do(http.MethodGet, "/api/v1/posts/missing", "", nil, requestIdHeader, tc.given)
+			id := resp.header.Get(requestIdHeader)
+			if id == "" || (id == tc.given) != tc.reused {
+				t.Fatalf("got request id %q for %q", id, tc.given)
+			}
+
+			// the request is logged after the response is sent
+			for deadline := time.Now().Add(5 * time.Second); !logs.hasRecord("Handled HTTP request", id); {
+				if time.Now().After(deadline) {
+					t.Fatalf("request id %q is not logged", id)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	up := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+
+	for _, tc := range []struct {
+		name   string
+		checks []repo.HealthCheck
+		status int
+		want   string
+	}{
+		{name: "Up", checks: []repo.HealthCheck{{Name: "redis", Optional: true, Ping: up}, {Name: "mongo", Ping: up}},
+			status: http.StatusOK, want: statusUp},
+		{name: "CacheDown", checks: []repo.HealthCheck{{Name: "redis", Optional: true, Ping: down}, {Name: "mongo", Ping: up}},
+			status: http.StatusOK, want: statusDegraded},
+		{name: "StorageDown", checks: []repo.HealthCheck{{Name: "redis", Optional: true, Ping: down}, {Name: "mongo", Ping: down}},
+			status: http.StatusServiceUnavailable, want: statusDown},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHarness(t, withRepository(failingRepository{Repository: repo.NewMemoryRepository(), checks: tc.checks}))
+
+			var ready ReadinessResponse
+			h.do(http.MethodGet, "/maintenance/ready", "", nil).decode(t, tc.status, &ready)
+			if ready.Status != tc.want || len(ready.Dependencies) != len(tc.checks) {
+				t.Fatalf("got readiness %+v, want %s", ready, tc.want)
+			}
+		})
+	}
+}
+
+// TestAllMode runs a single-node deployment: sqlite storage and tasks processed through the local queue
+func TestAllMode(t *testing.T) {
+	cfg := config.Default()
+	cfg.Mode = config.ModeAll
+	cfg.Storage = config.StorageSQLite
+	cfg.SQLite.Path = filepath.Join(t.TempDir(), "microblog.db")
+	cfg.Queue.LocalSize = 1
+	cfg.Queue.Concurrency = 1
+
+	r, err := repo.NewSQLiteRepository(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+
+	queue := StartLocalQueue(cfg.Queue)
+	t.Cleanup(queue.Close)
+
+	h := newHarness(t, withRepository(r), withProducer(NewLocalProducer(NewConsumer(r, cfg.Queue), queue)))
+
+	h.subscribe("bb", "aa")
+	var posts []model.Post
+	for i := 0; i < 5; i++ {
+		posts = append([]model.Post{h.createPost("aa", "post "+strconv.Itoa(i))}, posts...)
+	}
+
+	// tasks queued before the queue is closed are processed
+	queue.Close()
+
+	feed := h.collectPages("/api/v1/feed?size=2", "bb")
+	if len(feed) != len(posts) {
+		t.Fatalf("got %d posts in feed, want %d", len(feed), len(posts))
+	}
+	for i := range posts {
+		if feed[i] != posts[i] {
+			t.Fatalf("feed post %d is %+v, want %+v", i, feed[i], posts[i])
+		}
+	}
+
+	resp := h.do(http.MethodPost, "/api/v1/posts", "aa", map[string]string{"text": "late"})
+	if code := resp.errorCode(t, http.StatusInternalServerError); code != internalErrorCode {
+		t.Fatalf("got error code %q after the queue is closed", code)
+	}
+}
+
+func userIds(users []model.UserId) []string {
+	result := make([]string, len(users))
+	for i, u := range users {
+		result[i] = string(u)
+	}
+	return result
+}
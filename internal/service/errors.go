@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"microblog/internal/logging"
+	"microblog/internal/model"
+	"microblog/internal/utils"
+	"net/http"
+)
+
+const internalErrorCode = "internal_error"
+
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code      string             `json:"code"`
+	Message   string             `json:"message"`
+	RequestId string             `json:"requestId,omitempty"`
+	Fields    []model.FieldError `json:"fields,omitempty"`
+}
+
+type errorMapping struct {
+	err     error
+	status  int
+	message string
+}
+
+// errorMappings is the single place where domain errors are translated into HTTP statuses
+var errorMappings = []errorMapping{
+	{model.InvalidUserId, http.StatusUnauthorized, "The user id is not in the request, or is in the wrong format"},
+	{model.NotPostAuthor, http.StatusForbidden, "The post is published by another user"},
+	{model.PostNotFound, http.StatusNotFound, "The post with the specified identifier does not exist"},
+	{model.RouteNotFound, http.StatusNotFound, "The requested resource does not exist"},
+	{model.MethodNotAllowed, http.StatusMethodNotAllowed, "The method is not allowed for the requested resource"},
+	{model.InvalidPageToken, http.StatusBadRequest, "The page token is invalid"},
+	{model.InvalidPageCursor, http.StatusBadRequest, "The since or before post id is invalid, or is combined with another page parameter"},
+	{model.InvalidPageSize, http.StatusBadRequest, "The page size is invalid"},
+	{model.InvalidRequestBody, http.StatusBadRequest, "The request body is invalid"},
+	{model.SelfSubscription, http.StatusBadRequest, "Subscribing to yourself is not allowed"},
+	{model.AlreadySubscribed, http.StatusConflict, "The user is already subscribed"},
+	{model.InvalidIdempotencyKey, http.StatusBadRequest, "The Idempotency-Key header is invalid"},
+	{model.IdempotencyKeyReused, http.StatusConflict, "The Idempotency-Key was already used with a different request"},
+	{model.IdempotentRequestInProgress, http.StatusConflict, "A request with the same Idempotency-Key is still in progress"},
+	{model.RateLimitExceeded, http.StatusTooManyRequests, "Too many requests, retry later"},
+	{model.IdempotencyUnavailable, http.StatusServiceUnavailable, "Requests with an Idempotency-Key can't be handled now, retry later"},
+	{model.PostCreationFailed, http.StatusInternalServerError, "Failed to create the post"},
+}
+
+// writeError responds with JSON error envelope. Unknown errors are logged and reported as internal errors without details
+func writeError(rw http.ResponseWriter, r *http.Request, err error) {
+	body := ErrorBody{
+		Code:      internalErrorCode,
+		Message:   "Internal server error",
+		RequestId: logging.RequestId(r.Context()),
+	}
+	status := http.StatusInternalServerError
+
+	mapped := false
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.err) {
+			body.Code = mapping.err.Error()
+			body.Message = mapping.message
+			status = mapping.status
+			mapped = true
+			break
+		}
+	}
+
+	var validationErr *model.ValidationError
+	if errors.As(err, &validationErr) {
+		body.Fields = validationErr.Fields
+	}
+
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "Failed to handle request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Bool("known", mapped),
+			slog.Any("error", err))
+	}
+
+	utils.WriteResponseBodyWithStatus(rw, status, ErrorResponse{Error: body})
+}
+
+func notFoundHandler(rw http.ResponseWriter, r *http.Request) {
+	writeError(rw, r, model.RouteNotFound)
+}
+
+func methodNotAllowedHandler(rw http.ResponseWriter, r *http.Request) {
+	writeError(rw, r, model.MethodNotAllowed)
+}
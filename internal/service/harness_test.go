@@ -0,0 +1,322 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"microblog/internal/config"
+	"microblog/internal/idempotency"
+	"microblog/internal/model"
+	"microblog/internal/ratelimit"
+	"microblog/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// harness serves the real router over a pluggable repository and producer
+type harness struct {
+	t        *testing.T
+	cfg      config.Config
+	repo     repo.Repository
+	producer TaskProducer
+	limiter  ratelimit.Limiter
+	server   *httptest.Server
+}
+
+type harnessOption func(h *harness)
+
+func withRepository(r repo.Repository) harnessOption {
+	return func(h *harness) { h.repo = r }
+}
+
+func withProducer(p TaskProducer) harnessOption {
+	return func(h *harness) { h.producer = p }
+}
+
+func withLimiter(l ratelimit.Limiter) harnessOption {
+	return func(h *harness) { h.limiter = l }
+}
+
+func withConfig(update func(cfg *config.Config)) harnessOption {
+	return func(h *harness) { update(&h.cfg) }
+}
+
+// newHarness starts a server over the memory repository, tasks are queued until queuedProducer.RunTasks is called.
+// Responses are validated against the api spec, so every scenario checks the spec as well
+func newHarness(t *testing.T, opts ...harnessOption) *harness {
+	t.Helper()
+
+	h := &harness{t: t, cfg: config.Default()}
+	h.cfg.Storage = config.StorageMemory
+	h.cfg.RateLimit.Enabled = false
+	h.cfg.OpenAPI.ValidateResponses = true
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	if h.repo == nil {
+		h.repo = repo.NewMemoryRepository()
+	}
+	if h.producer == nil {
+		h.producer = newQueuedProducer(NewConsumer(h.repo, h.cfg.Queue))
+	}
+
+	var rateLimit *RateLimitMiddleware
+	if h.limiter == nil {
+		h.limiter = ratelimit.NewMemoryLimiter()
+	}
+	if h.cfg.RateLimit.Enabled {
+		rateLimit = NewRateLimitMiddleware(h.limiter, h.cfg.RateLimit)
+	}
+
+	validator, err := NewOpenAPIValidator(h.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := createRouter(
+		NewHTTPHandler(h.cfg, h.repo, h.producer),
+		NewHealthHandler(h.cfg.Health, h.repo.HealthChecks()),
+		NewIdempotencyMiddleware(idempotency.NewMemoryStore(h.cfg.Idempotency), h.cfg),
+		rateLimit,
+		validator,
+	)
+
+	h.server = httptest.NewServer(router)
+	t.Cleanup(h.server.Close)
+
+	return h
+}
+
+// runTasks processes all tasks sent so far by the default producer
+func (h *harness) runTasks() {
+	h.t.Helper()
+
+	producer, ok := h.producer.(*queuedProducer)
+	if !ok {
+		h.t.Fatal("harness is not using queuedProducer")
+	}
+	producer.RunTasks(h.t)
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// decode unmarshals the body of the response with expected status
+func (r response) decode(t *testing.T, status int, v any) {
+	t.Helper()
+
+	if r.status != status {
+		t.Fatalf("got status %d, want %d: %s", r.status, status, r.body)
+	}
+	if v == nil {
+		return
+	}
+	if err := json.Unmarshal(r.body, v); err != nil {
+		t.Fatalf("failed to decode %s: %v", r.body, err)
+	}
+}
+
+// errorCode returns the code of the error envelope, checking the status
+func (r response) errorCode(t *testing.T, status int) string {
+	t.Helper()
+
+	var body ErrorResponse
+	r.decode(t, status, &body)
+	if body.Error.RequestId == "" || r.header.Get(requestIdHeader) != body.Error.RequestId {
+		t.Fatalf("request id %q of the error does not match header %q", body.Error.RequestId, r.header.Get(requestIdHeader))
+	}
+	return body.Error.Code
+}
+
+// do sends a request on behalf of user (no user id header if user is empty), body is marshaled to JSON unless it is a string
+func (h *harness) do(method, path string, user model.UserId, body any, headers ...string) response {
+	h.t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewBufferString(b)
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, h.server.URL+path, reader)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if user != "" {
+		req.Header.Set("System-Design-User-Id", string(user))
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := h.server.Client().Do(req)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	return response{status: resp.StatusCode, header: resp.Header, body: raw}
+}
+
+func (h *harness) createPost(user model.UserId, text string) model.Post {
+	h.t.Helper()
+
+	var post model.Post
+	h.do(http.MethodPost, "/api/v1/posts", user, map[string]string{"text": text}).decode(h.t, http.StatusOK, &post)
+	return post
+}
+
+func (h *harness) subscribe(from, to model.UserId) {
+	h.t.Helper()
+
+	h.do(http.MethodPost, "/api/v1/users/"+string(to)+"/subscribe", from, nil).decode(h.t, http.StatusOK, nil)
+}
+
+// collectPages follows nextPage tokens of a paginated endpoint
+func (h *harness) collectPages(path string, user model.UserId) []model.Post {
+	h.t.Helper()
+
+	var result []model.Post
+	next := path
+	for next != "" {
+		var page GetPostPageResponse
+		h.do(http.MethodGet, next, user, nil).decode(h.t, http.StatusOK, &page)
+		result = append(result, page.Posts...)
+
+		next = ""
+		if page.NextPage != nil {
+			next = path + "&page=" + string(*page.NextPage)
+		}
+	}
+	return result
+}
+
+var _ TaskProducer = (*queuedProducer)(nil)
+
+// queuedProducer keeps tasks in memory and runs them synchronously on demand
+type queuedProducer struct {
+	mu       sync.Mutex
+	consumer *Consumer
+	tasks    []func(ctx context.Context) (string, error)
+}
+
+func newQueuedProducer(consumer *Consumer) *queuedProducer {
+	return &queuedProducer{consumer: consumer}
+}
+
+func (p *queuedProducer) SendPostTask(_ context.Context, post model.Post) error {
+	serialized, _ := json.Marshal(post)
+	p.enqueue(func(ctx context.Context) (string, error) {
+		return p.consumer.StreamNewPost(ctx, string(serialized))
+	})
+	return nil
+}
+
+func (p *queuedProducer) SendFeedTask(_ context.Context, from, to model.UserId) error {
+	p.enqueue(func(ctx context.Context) (string, error) {
+		return p.consumer.RebuildFeed(ctx, string(from), string(to))
+	})
+	return nil
+}
+
+func (p *queuedProducer) SendFeedCleanupTask(_ context.Context, owner model.UserId, ids []model.PostId) error {
+	serialized, _ := json.Marshal(ids)
+	p.enqueue(func(ctx context.Context) (string, error) {
+		return p.consumer.CleanFeed(ctx, string(owner), string(serialized))
+	})
+	return nil
+}
+
+func (p *queuedProducer) enqueue(task func(ctx context.Context) (string, error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tasks = append(p.tasks, task)
+}
+
+func (p *queuedProducer) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.tasks)
+}
+
+func (p *queuedProducer) RunTasks(t *testing.T) {
+	t.Helper()
+
+	p.mu.Lock()
+	tasks := p.tasks
+	p.tasks = nil
+	p.mu.Unlock()
+
+	for _, task := range tasks {
+		if status, err := task(context.Background()); err != nil {
+			t.Fatalf("task failed with status %q: %v", status, err)
+		}
+	}
+}
+
+var _ TaskProducer = failingProducer{}
+
+type failingProducer struct {
+	err error
+}
+
+func (p failingProducer) SendPostTask(context.Context, model.Post) error {
+	return p.err
+}
+
+func (p failingProducer) SendFeedTask(context.Context, model.UserId, model.UserId) error {
+	return p.err
+}
+
+func (p failingProducer) SendFeedCleanupTask(context.Context, model.UserId, []model.PostId) error {
+	return p.err
+}
+
+// failingRepository fails the selected methods of the wrapped repository
+type failingRepository struct {
+	repo.Repository
+	createPost error
+	getFeed    error
+	checks     []repo.HealthCheck
+}
+
+func (r failingRepository) HealthChecks() []repo.HealthCheck {
+	return r.checks
+}
+
+func (r failingRepository) CreatePost(ctx context.Context, id model.UserId, post model.Post) (model.Post, error) {
+	if r.createPost != nil {
+		return model.Post{}, r.createPost
+	}
+	return r.Repository.CreatePost(ctx, id, post)
+}
+
+func (r failingRepository) GetFeedPosts(ctx context.Context, id model.UserId, page model.PageRequest) (model.FeedPage, error) {
+	if r.getFeed != nil {
+		return model.FeedPage{}, r.getFeed
+	}
+	return r.Repository.GetFeedPosts(ctx, id, page)
+}
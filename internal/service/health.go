@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"microblog/internal/config"
+	"microblog/internal/repo"
+	"microblog/internal/utils"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	statusUp       = "up"
+	statusDegraded = "degraded"
+	statusDown     = "down"
+)
+
+type HealthHandler struct {
+	checks  []repo.HealthCheck
+	timeout time.Duration
+}
+
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+func NewHealthHandler(cfg config.HealthConfig, checks []repo.HealthCheck) *HealthHandler {
+	return &HealthHandler{checks: checks, timeout: cfg.CheckTimeout}
+}
+
+// newBrokerHealthCheck pings redis which is used by machinery as a task broker
+func newBrokerHealthCheck(cfg config.Config) (repo.HealthCheck, func() error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:        cfg.Redis.Addr,
+		DialTimeout: cfg.Health.CheckTimeout,
+		PoolSize:    1,
+	})
+
+	check := repo.HealthCheck{
+		Name: "broker",
+		Ping: func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		},
+	}
+
+	return check, client.Close
+}
+
+// Live reports that the process is running and able to serve HTTP, without touching dependencies
+func (h *HealthHandler) Live(rw http.ResponseWriter, _ *http.Request) {
+	rw.WriteHeader(http.StatusOK)
+}
+
+// Ready pings every dependency concurrently and fails with 503 if any required one is unavailable.
+// Unavailable optional dependencies (the cache) make the service degraded, but still ready
+func (h *HealthHandler) Ready(rw http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	statuses := make([]DependencyStatus, len(h.checks))
+	var wg sync.WaitGroup
+
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func(i int, check repo.HealthCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check.Ping(ctx)
+
+			statuses[i] = DependencyStatus{
+				Name:      check.Name,
+				Status:    statusUp,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				statuses[i].Status = statusDown
+				statuses[i].Error = err.Error()
+			}
+		}(i, check)
+	}
+
+	wg.Wait()
+
+	respBody := ReadinessResponse{Status: statusUp, Dependencies: statuses}
+	code := http.StatusOK
+
+	for i, status := range statuses {
+		switch {
+		case status.Status == statusUp:
+		case h.checks[i].Optional:
+			if respBody.Status == statusUp {
+				respBody.Status = statusDegraded
+			}
+		default:
+			respBody.Status = statusDown
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	utils.WriteResponseBodyWithStatus(rw, code, respBody)
+}
+
+func registerHealthRoutes(r *mux.Router, health *HealthHandler) {
+	r.HandleFunc("/maintenance/live", health.Live).Methods(http.MethodGet)
+	r.HandleFunc("/maintenance/ready", health.Ready).Methods(http.MethodGet)
+}
+
+// newHealthServer creates a side HTTP listener with maintenance endpoints and metrics, used in WORKER mode
+func newHealthServer(cfg config.Config, health *HealthHandler) *http.Server {
+	r := mux.NewRouter()
+	registerHealthRoutes(r, health)
+	r.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
+
+	return &http.Server{
+		Handler:      r,
+		Addr:         "0.0.0.0:" + strconv.Itoa(cfg.Health.WorkerPort),
+		WriteTimeout: cfg.Server.WriteTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+	}
+}
@@ -1,69 +1,90 @@
 package service
 
 import (
-	"encoding/json"
 	"errors"
+	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"log/slog"
+	"microblog/internal/breaker"
+	"microblog/internal/config"
+	"microblog/internal/idempotency"
 	"microblog/internal/model"
+	"microblog/internal/pagination"
+	"microblog/internal/ratelimit"
 	"microblog/internal/repo"
 	"microblog/internal/utils"
+	"microblog/internal/validation"
 	"net/http"
-	"os"
-	"time"
+	"strconv"
 )
 
 type HTTPHandler struct {
-	repo     repo.Repository
-	producer Producer
+	repo       repo.Repository
+	producer   TaskProducer
+	pagination config.PaginationConfig
+	pages      *pagination.Signer
+	validator  *validation.PostValidator
 }
 
 type GetPostPageResponse struct {
 	Posts    []model.Post     `json:"posts"`
 	NextPage *model.PageToken `json:"nextPage,omitempty"`
+	PrevPage *model.PageToken `json:"prevPage,omitempty"`
+}
+
+type UnreadCountResponse struct {
+	Count int `json:"count"`
 }
 
 type GetUsersResponse struct {
 	Users []model.UserId `json:"users"`
 }
 
-func NewHTTPHandler(repo repo.Repository) (*HTTPHandler, error) {
-	p, err := StartProducer(repo)
-
-	if err != nil {
-		return nil, err
+func NewHTTPHandler(cfg config.Config, repo repo.Repository, producer TaskProducer) *HTTPHandler {
+	return &HTTPHandler{
+		repo:       repo,
+		producer:   producer,
+		pagination: cfg.Pagination,
+		pages:      pagination.NewSigner(cfg.Pagination.TokenSecret),
+		validator:  validation.NewPostValidator(cfg.Validation),
 	}
+}
 
-	return &HTTPHandler{
-		repo:     repo,
-		producer: p,
-	}, nil
+// newTaskProducer sends tasks to the worker through the broker. In ALL mode they are passed through the local queue,
+// with memory storage they are processed in background goroutines of the server
+func newTaskProducer(cfg config.Config, repo repo.Repository, queue *LocalQueue) (TaskProducer, error) {
+	if queue != nil || cfg.Storage == config.StorageMemory {
+		return NewLocalProducer(NewConsumer(repo, cfg.Queue), queue), nil
+	}
+	return StartProducer(cfg, repo)
 }
 
 func (h *HTTPHandler) CreatePost(rw http.ResponseWriter, r *http.Request) {
 	userId, err := utils.GetAuthorizedUserId(r)
 
 	if err != nil {
-		http.Error(rw, "Empty or Invalid User Id!", http.StatusUnauthorized)
+		writeError(rw, r, err)
 		return
 	}
 
-	var post model.Post
-	err = json.NewDecoder(r.Body).Decode(&post)
+	post, err := h.validator.DecodePost(rw, r)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusBadRequest)
+		writeError(rw, r, err)
 		return
 	}
 
 	post, err = h.repo.CreatePost(r.Context(), userId, post)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		writeError(rw, r, err)
 		return
 	}
 
 	err = h.producer.SendPostTask(r.Context(), post)
 
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		writeError(rw, r, err)
 		return
 	}
 
@@ -74,7 +95,7 @@ func (h *HTTPHandler) EditPost(rw http.ResponseWriter, r *http.Request) {
 	userId, err := utils.GetAuthorizedUserId(r)
 
 	if err != nil {
-		http.Error(rw, "Empty or Invalid User Id!", http.StatusUnauthorized)
+		writeError(rw, r, err)
 		return
 	}
 
@@ -82,26 +103,25 @@ func (h *HTTPHandler) EditPost(rw http.ResponseWriter, r *http.Request) {
 	postId, ok := vars["postId"]
 
 	if !ok {
-		http.Error(rw, "Invalid post id in path", http.StatusNotFound)
+		writeError(rw, r, model.PostNotFound)
 		return
 	}
 
 	oldPost, err := h.repo.GetPostById(r.Context(), model.PostId(postId))
 
 	if err != nil {
-		http.Error(rw, "Invalid post id in path", http.StatusNotFound)
+		writeError(rw, r, err)
 		return
 	}
 
 	if oldPost.AuthorId != userId {
-		http.Error(rw, "Given user id is not a creator of requested post", http.StatusForbidden)
+		writeError(rw, r, model.NotPostAuthor)
 		return
 	}
 
-	var postToEdit model.Post
-	err = json.NewDecoder(r.Body).Decode(&postToEdit)
+	postToEdit, err := h.validator.DecodePost(rw, r)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusBadRequest)
+		writeError(rw, r, err)
 		return
 	}
 
@@ -110,7 +130,7 @@ func (h *HTTPHandler) EditPost(rw http.ResponseWriter, r *http.Request) {
 	resultedPost, err := h.repo.EditPost(r.Context(), userId, postToEdit)
 
 	if err != nil {
-		http.Error(rw, "Invalid post id in path", http.StatusNotFound)
+		writeError(rw, r, err)
 		return
 	}
 
@@ -122,14 +142,14 @@ func (h *HTTPHandler) GetPostById(rw http.ResponseWriter, r *http.Request) {
 	postId, ok := vars["postId"]
 
 	if !ok {
-		http.Error(rw, "Invalid post id in path", http.StatusNotFound)
+		writeError(rw, r, model.PostNotFound)
 		return
 	}
 
 	post, err := h.repo.GetPostById(r.Context(), model.PostId(postId))
 
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusNotFound)
+		writeError(rw, r, err)
 		return
 	}
 
@@ -141,27 +161,20 @@ func (h *HTTPHandler) GetPosts(rw http.ResponseWriter, r *http.Request) {
 	userId, ok := vars["userId"]
 
 	if !ok {
-		http.Error(rw, "Invalid user id in path", http.StatusBadRequest)
+		writeError(rw, r, model.InvalidUserId)
 		return
 	}
 
-	pageToken, err := utils.GetPageToken(r)
+	page, err := h.pageRequest(r, pagination.ListPosts, model.UserId(userId))
 	if err != nil {
-		http.Error(rw, "Invalid Page Token", http.StatusUnauthorized)
+		writeError(rw, r, err)
 		return
 	}
 
-	size, err := utils.GetSize(r)
+	posts, cursors, err := h.repo.GetPosts(r.Context(), model.UserId(userId), page)
 
 	if err != nil {
-		http.Error(rw, "Invalid size param", http.StatusBadRequest)
-		return
-	}
-
-	posts, nextPageToken, err := h.repo.GetPosts(r.Context(), model.UserId(userId), pageToken, size)
-
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusBadRequest)
+		writeError(rw, r, err)
 		return
 	}
 
@@ -171,10 +184,7 @@ func (h *HTTPHandler) GetPosts(rw http.ResponseWriter, r *http.Request) {
 
 	var respBody GetPostPageResponse
 	respBody.Posts = posts
-
-	if nextPageToken != model.EmptyPage {
-		respBody.NextPage = &nextPageToken
-	}
+	respBody.NextPage, respBody.PrevPage = h.adjacentPages(pagination.ListPosts, model.UserId(userId), cursors)
 
 	utils.WriteResponseBody(rw, respBody)
 }
@@ -183,7 +193,7 @@ func (h *HTTPHandler) Subscribe(rw http.ResponseWriter, r *http.Request) {
 	fromUserId, err := utils.GetAuthorizedUserId(r)
 
 	if err != nil {
-		http.Error(rw, "Empty or Invalid User Id!", http.StatusUnauthorized)
+		writeError(rw, r, err)
 		return
 	}
 
@@ -191,17 +201,18 @@ func (h *HTTPHandler) Subscribe(rw http.ResponseWriter, r *http.Request) {
 	toUserId, ok := vars["userId"]
 
 	if !ok {
-		http.Error(rw, "Invalid user id in path", http.StatusBadRequest)
+		writeError(rw, r, model.InvalidUserId)
 		return
 	}
 
 	err = h.repo.Subscribe(r.Context(), fromUserId, model.UserId(toUserId))
 
 	if err != nil {
+		// re-subscribing is considered a successful request
 		if errors.Is(err, model.AlreadySubscribed) {
 			rw.WriteHeader(http.StatusOK)
 		} else {
-			http.Error(rw, err.Error(), http.StatusBadRequest)
+			writeError(rw, r, err)
 		}
 		return
 	}
@@ -209,7 +220,7 @@ func (h *HTTPHandler) Subscribe(rw http.ResponseWriter, r *http.Request) {
 	err = h.producer.SendFeedTask(r.Context(), fromUserId, model.UserId(toUserId))
 
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		writeError(rw, r, err)
 		return
 	}
 
@@ -219,13 +230,13 @@ func (h *HTTPHandler) Subscribe(rw http.ResponseWriter, r *http.Request) {
 func (h *HTTPHandler) GetSubscriptions(rw http.ResponseWriter, r *http.Request) {
 	userId, err := utils.GetAuthorizedUserId(r)
 	if err != nil {
-		http.Error(rw, "Empty or Invalid User Id!", http.StatusUnauthorized)
+		writeError(rw, r, err)
 		return
 	}
 
 	result, err := h.repo.GetSubscriptions(r.Context(), userId)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusBadRequest)
+		writeError(rw, r, err)
 		return
 	}
 
@@ -242,13 +253,13 @@ func (h *HTTPHandler) GetSubscriptions(rw http.ResponseWriter, r *http.Request)
 func (h *HTTPHandler) GetSubscribers(rw http.ResponseWriter, r *http.Request) {
 	userId, err := utils.GetAuthorizedUserId(r)
 	if err != nil {
-		http.Error(rw, "Empty or Invalid User Id!", http.StatusUnauthorized)
+		writeError(rw, r, err)
 		return
 	}
 
 	result, err := h.repo.GetSubscribers(r.Context(), userId)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusBadRequest)
+		writeError(rw, r, err)
 		return
 	}
 
@@ -265,96 +276,228 @@ func (h *HTTPHandler) GetSubscribers(rw http.ResponseWriter, r *http.Request) {
 func (h *HTTPHandler) GetFeed(rw http.ResponseWriter, r *http.Request) {
 	userId, err := utils.GetAuthorizedUserId(r)
 	if err != nil {
-		http.Error(rw, "Empty or Invalid User Id!", http.StatusUnauthorized)
+		writeError(rw, r, err)
 		return
 	}
 
-	pageToken, err := utils.GetPageToken(r)
+	page, err := h.pageRequest(r, pagination.ListFeed, userId)
 	if err != nil {
-		http.Error(rw, "Invalid Page Token", http.StatusUnauthorized)
+		writeError(rw, r, err)
 		return
 	}
 
-	size, err := utils.GetSize(r)
+	feed, err := h.repo.GetFeedPosts(r.Context(), userId, page)
 
 	if err != nil {
-		http.Error(rw, "Invalid size param", http.StatusBadRequest)
+		writeError(rw, r, err)
 		return
 	}
 
-	feedMetadata, nextPageToken, err := h.repo.GetFeed(r.Context(), userId, pageToken, size)
+	// posts deleted after fan-out are skipped, their feed items are removed in background
+	if len(feed.Dangling) > 0 {
+		if err = h.producer.SendFeedCleanupTask(r.Context(), userId, feed.Dangling); err != nil {
+			slog.WarnContext(r.Context(), "Failed to send feed cleanup task", slog.String("feed_owner", string(userId)), slog.Any("error", err))
+		}
+	}
+
+	var respBody GetPostPageResponse
+	respBody.Posts = feed.Posts
+	if respBody.Posts == nil {
+		respBody.Posts = []model.Post{}
+	}
+	respBody.NextPage, respBody.PrevPage = h.adjacentPages(pagination.ListFeed, userId, feed.Cursors)
 
+	utils.WriteResponseBody(rw, respBody)
+}
+
+func (h *HTTPHandler) GetFeedUnreadCount(rw http.ResponseWriter, r *http.Request) {
+	userId, err := utils.GetAuthorizedUserId(r)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusBadRequest)
+		writeError(rw, r, err)
 		return
 	}
 
-	if feedMetadata == nil {
-		feedMetadata = []model.FeedMetadataDocument{}
+	since, err := utils.GetPostCursor(r, "since")
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+
+	count, err := h.repo.CountFeed(r.Context(), userId, since, h.pagination.MaxUnreadCount)
+	if err != nil {
+		writeError(rw, r, err)
+		return
 	}
 
-	var posts []model.Post
-	posts = []model.Post{}
+	utils.WriteResponseBody(rw, UnreadCountResponse{Count: count})
+}
+
+// pageRequest reads the requested page of the owner's list: a page token, or a post id in since (newer posts)
+// or before (older posts). Without any of them the newest page is requested
+func (h *HTTPHandler) pageRequest(r *http.Request, list pagination.List, owner model.UserId) (model.PageRequest, error) {
+	page := model.PageRequest{Cursor: model.EmptyPage, Direction: model.DirectionOlder}
 
-	for _, metadata := range feedMetadata {
-		post, err := h.repo.GetPostById(r.Context(), metadata.PostId)
+	pageToken, err := utils.GetPageToken(r)
+	if err != nil {
+		return page, err
+	}
+	since, err := utils.GetPostCursor(r, "since")
+	if err != nil {
+		return page, err
+	}
+	before, err := utils.GetPostCursor(r, "before")
+	if err != nil {
+		return page, err
+	}
 
+	switch {
+	case pageToken != model.EmptyPage && (since != model.EmptyPage || before != model.EmptyPage),
+		since != model.EmptyPage && before != model.EmptyPage:
+		return page, model.InvalidPageCursor
+	case pageToken != model.EmptyPage:
+		verified, err := h.pages.Verify(pageToken, list, owner)
 		if err != nil {
-			http.Error(rw, err.Error(), http.StatusBadRequest)
-			return
+			return page, err
 		}
-
-		posts = append(posts, post)
+		page.Cursor, page.Direction = verified.Cursor, verified.Direction
+	case since != model.EmptyPage:
+		page.Cursor, page.Direction = since, model.DirectionNewer
+	case before != model.EmptyPage:
+		page.Cursor = before
 	}
 
-	var respBody GetPostPageResponse
-	respBody.Posts = posts
+	page.Size, err = utils.GetSize(r, h.pagination)
+	return page, err
+}
 
-	if nextPageToken != model.EmptyPage {
-		respBody.NextPage = &nextPageToken
+// adjacentPages signs storage cursors returned by the repository, nil means there is no such page
+func (h *HTTPHandler) adjacentPages(list pagination.List, owner model.UserId, cursors model.PageCursors) (next, prev *model.PageToken) {
+	sign := func(cursor model.PageToken, direction model.Direction) *model.PageToken {
+		if cursor == model.EmptyPage {
+			return nil
+		}
+		token := h.pages.Sign(pagination.Page{List: list, Owner: owner, Cursor: cursor, Direction: direction})
+		return &token
 	}
 
-	utils.WriteResponseBody(rw, respBody)
+	return sign(cursors.Older, model.DirectionOlder), sign(cursors.Newer, model.DirectionNewer)
 }
 
-func createRouter(handler *HTTPHandler) *mux.Router {
+// createRouter builds the API router, rateLimit and validator may be nil if they are disabled
+func createRouter(handler *HTTPHandler, health *HealthHandler, idempotent *IdempotencyMiddleware, rateLimit *RateLimitMiddleware, validator *OpenAPIValidator) *mux.Router {
 	r := mux.NewRouter()
 
-	r.HandleFunc("/api/v1/posts", handler.CreatePost).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/posts", idempotent.Wrap(handler.CreatePost)).Methods(http.MethodPost)
 	r.HandleFunc("/api/v1/posts/{postId:[A-Za-z0-9_\\-]+}", handler.EditPost).Methods(http.MethodPatch)
 	r.HandleFunc("/api/v1/posts/{postId:[A-Za-z0-9_\\-]+}", handler.GetPostById).Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/users/{userId:[0-9a-f]+}/posts", handler.GetPosts).Methods(http.MethodGet)
-	r.HandleFunc("/api/v1/users/{userId:[0-9a-f]+}/subscribe", handler.Subscribe).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/users/{userId:[0-9a-f]+}/subscribe", idempotent.Wrap(handler.Subscribe)).Methods(http.MethodPost)
 	r.HandleFunc("/api/v1/subscriptions", handler.GetSubscriptions).Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/subscribers", handler.GetSubscribers).Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/feed", handler.GetFeed).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/feed/unread-count", handler.GetFeedUnreadCount).Methods(http.MethodGet)
+	r.HandleFunc(specPath, serveSpec).Methods(http.MethodGet)
 	r.HandleFunc("/maintenance/ping", handler.Ping).Methods(http.MethodGet)
+	registerHealthRoutes(r, health)
+	r.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
+
+	r.NotFoundHandler = requestIdMiddleware(http.HandlerFunc(notFoundHandler))
+	r.MethodNotAllowedHandler = requestIdMiddleware(http.HandlerFunc(methodNotAllowedHandler))
+
+	r.Use(requestIdMiddleware)
+	r.Use(otelmux.Middleware("microblog"))
+	r.Use(metricsMiddleware)
+	if rateLimit != nil {
+		r.Use(rateLimit.Middleware)
+	}
+	if validator != nil {
+		r.Use(validator.Middleware)
+	}
 
 	return r
 }
 
-func NewServer(repo repo.Repository) (*http.Server, error) {
-	handler, err := NewHTTPHandler(repo)
+// NewServer creates the API server, queue is the local task queue of ALL mode and nil in other modes
+func NewServer(cfg config.Config, repo repo.Repository, queue *LocalQueue) (*http.Server, error) {
+	producer, err := newTaskProducer(cfg, repo, queue)
 
 	if err != nil {
 		return nil, err
 	}
 
-	port, ok := os.LookupEnv("SERVER_PORT")
-	if !ok {
-		port = "8080"
+	handler := NewHTTPHandler(cfg, repo, producer)
+
+	checks := repo.HealthChecks()
+	var store idempotency.Store
+	var limiter ratelimit.Limiter
+	var onShutdown []func() error
+
+	if cfg.Standalone() {
+		store = idempotency.NewMemoryStore(cfg.Idempotency)
+		limiter = ratelimit.NewMemoryLimiter()
+	} else {
+		redisClient := newRedisClient(cfg)
+		store = idempotency.NewRedisStore(redisClient, cfg.Idempotency)
+		limiter = ratelimit.NewRedisLimiter(redisClient)
+
+		onShutdown = append(onShutdown, redisClient.Close)
 	}
 
-	srv := &http.Server{
-		Handler:      createRouter(handler),
-		Addr:         "0.0.0.0:" + port,
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
+	if _, ok := producer.(*Producer); ok {
+		brokerCheck, closeBrokerCheck := newBrokerHealthCheck(cfg)
+		checks = append(checks, brokerCheck)
+		onShutdown = append(onShutdown, closeBrokerCheck)
+	}
+
+	var rateLimit *RateLimitMiddleware
+	if cfg.RateLimit.Enabled {
+		rateLimit = NewRateLimitMiddleware(limiter, cfg.RateLimit)
+	}
+
+	var validator *OpenAPIValidator
+	if cfg.OpenAPI.ValidateRequests || cfg.OpenAPI.ValidateResponses {
+		if validator, err = NewOpenAPIValidator(cfg); err != nil {
+			return nil, err
+		}
 	}
 
+	srv := &http.Server{
+		Handler:      createRouter(handler, NewHealthHandler(cfg.Health, checks), NewIdempotencyMiddleware(store, cfg), rateLimit, validator),
+		Addr:         "0.0.0.0:" + strconv.Itoa(cfg.Server.Port),
+		WriteTimeout: cfg.Server.WriteTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+	}
+	srv.RegisterOnShutdown(func() {
+		for _, f := range onShutdown {
+			_ = f()
+		}
+	})
+
 	return srv, nil
 }
 
+// newRedisClient creates the client of rate limits and idempotency records. Commands time out like cache commands
+// and fail fast while its own breaker is open, so a slow or unavailable redis doesn't hold requests in middleware
+func newRedisClient(cfg config.Config) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:         cfg.Redis.Addr,
+		DialTimeout:  cfg.Redis.ConnectTimeout,
+		ReadTimeout:  cfg.Redis.OperationTimeout,
+		WriteTimeout: cfg.Redis.OperationTimeout,
+	})
+
+	cb := breaker.New(cfg.Redis.BreakerThreshold, cfg.Redis.BreakerCooldown, func(open bool) {
+		if open {
+			slog.Warn("Redis is unavailable, rate limits are not applied and idempotency keys are rejected")
+		} else {
+			slog.Info("Redis is available again for rate limits and idempotency keys")
+		}
+	})
+	client.AddHook(breaker.RedisHook{Breaker: cb})
+
+	return client
+}
+
 func (h *HTTPHandler) Ping(rw http.ResponseWriter, _ *http.Request) {
 	rw.WriteHeader(http.StatusOK)
 }
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"microblog/internal/breaker"
+	"microblog/internal/config"
+	"microblog/internal/idempotency"
+	"microblog/internal/model"
+	"microblog/internal/utils"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+var idempotencyKeyPattern = regexp.MustCompile(`^[\x21-\x7e]{1,255}$`)
+
+type IdempotencyMiddleware struct {
+	store idempotency.Store
+	// maxBodyBytes is the limit of request bodies, they are read at once to be fingerprinted
+	maxBodyBytes int64
+	// storeTimeout limits storing of the response, which is detached from the request
+	storeTimeout time.Duration
+	// retryAfter is suggested to clients while the store is unavailable, it's the cooldown of its breaker
+	retryAfter time.Duration
+}
+
+func NewIdempotencyMiddleware(store idempotency.Store, cfg config.Config) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		store:        store,
+		maxBodyBytes: int64(cfg.Validation.MaxBodyBytes),
+		storeTimeout: cfg.Redis.OperationTimeout,
+		retryAfter:   cfg.Redis.BreakerCooldown,
+	}
+}
+
+// responseCapture writes the response through and keeps a copy of it
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+// Wrap replays the first response of the user for a repeated Idempotency-Key.
+// Requests without the header are passed as is
+func (m *IdempotencyMiddleware) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(rw, r)
+			return
+		}
+
+		if !idempotencyKeyPattern.MatchString(key) {
+			writeError(rw, r, model.InvalidIdempotencyKey)
+			return
+		}
+
+		userId, err := utils.GetAuthorizedUserId(r)
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, m.maxBodyBytes))
+		if err != nil {
+			writeError(rw, r, model.InvalidRequestBody)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		storeKey := utils.CreateRedisKeyForIdempotency(userId, key)
+
+		record, claimed, err := m.store.Begin(r.Context(), storeKey, fingerprint)
+		if errors.Is(err, breaker.ErrOpen) {
+			// without the record a retry could repeat the request, so it's rejected until the store is back
+			rw.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(m.retryAfter)))
+			writeError(rw, r, model.IdempotencyUnavailable)
+			return
+		}
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+
+		if !claimed {
+			switch {
+			case record.Fingerprint != fingerprint:
+				writeError(rw, r, model.IdempotencyKeyReused)
+			case !record.Completed():
+				writeError(rw, r, model.IdempotentRequestInProgress)
+			default:
+				if record.ContentType != "" {
+					rw.Header().Set("Content-Type", record.ContentType)
+				}
+				rw.Header().Set(idempotentReplayedHeader, "true")
+				rw.WriteHeader(record.Status)
+				_, _ = rw.Write(record.Body)
+			}
+			return
+		}
+
+		capture := &responseCapture{ResponseWriter: rw}
+		next(capture, r)
+
+		if capture.status == 0 {
+			capture.status = http.StatusOK
+		}
+
+		// the request is already handled, so the response is stored even if the client has disconnected meanwhile,
+		// otherwise the key stays locked and a retry after the lock expires repeats the request
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), m.storeTimeout)
+		defer cancel()
+
+		// server failures are not stored, so the client is able to retry with the same key
+		if capture.status >= http.StatusInternalServerError {
+			err = m.store.Release(ctx, storeKey)
+		} else {
+			err = m.store.Complete(ctx, storeKey, idempotency.Record{
+				Fingerprint: fingerprint,
+				Status:      capture.status,
+				ContentType: rw.Header().Get("Content-Type"),
+				Body:        capture.body.Bytes(),
+			})
+		}
+
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to store idempotent response", slog.String("key", storeKey), slog.Any("error", err))
+		}
+	}
+}
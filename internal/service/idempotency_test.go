@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"microblog/internal/breaker"
+	"microblog/internal/config"
+	"microblog/internal/idempotency"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cancellableStore fails like the redis store does when the context is cancelled
+type cancellableStore struct {
+	idempotency.Store
+}
+
+func (s cancellableStore) Complete(ctx context.Context, key string, record idempotency.Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.Complete(ctx, key, record)
+}
+
+func (s cancellableStore) Release(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.Release(ctx, key)
+}
+
+// failingStore fails to claim keys like the redis store does when redis is unavailable
+type failingStore struct {
+	idempotency.Store
+	err error
+}
+
+func (s failingStore) Begin(context.Context, string, string) (idempotency.Record, bool, error) {
+	return idempotency.Record{}, false, s.err
+}
+
+// TestIdempotencyStoreUnavailable checks that requests are not handled without a record, and an open breaker
+// tells clients when to retry
+func TestIdempotencyStoreUnavailable(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		err        error
+		status     int
+		code       string
+		retryAfter string
+	}{
+		{name: "BreakerOpen", err: breaker.ErrOpen, status: http.StatusServiceUnavailable, code: "idempotency_unavailable", retryAfter: "90"},
+		{name: "Failure", err: errors.New("connection refused"), status: http.StatusInternalServerError, code: internalErrorCode},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Redis.BreakerCooldown = 90 * time.Second
+			m := NewIdempotencyMiddleware(failingStore{err: tc.err}, cfg)
+
+			handled := false
+			handler := m.Wrap(func(rw http.ResponseWriter, r *http.Request) {
+				handled = true
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(`{"text":"hello"}`))
+			req.Header.Set("System-Design-User-Id", "aa")
+			req.Header.Set(idempotencyKeyHeader, "key-1")
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			var body ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if handled {
+				t.Fatal("request was handled without an idempotency record")
+			}
+			if rec.Code != tc.status || body.Error.Code != tc.code {
+				t.Fatalf("got status %d and code %q, want %d and %q", rec.Code, body.Error.Code, tc.status, tc.code)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tc.retryAfter {
+				t.Fatalf("got Retry-After %q, want %q", got, tc.retryAfter)
+			}
+		})
+	}
+}
+
+// TestIdempotencyClientDisconnect checks that the response is stored when the client disconnects after
+// the request is handled, so a retry with the same key is replayed or repeated as usual
+func TestIdempotencyClientDisconnect(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		status      int
+		wantHandled int
+		wantStatus  int
+	}{
+		{name: "Completed", status: http.StatusOK, wantHandled: 1, wantStatus: http.StatusOK},
+		{name: "Released", status: http.StatusInternalServerError, wantHandled: 2, wantStatus: http.StatusInternalServerError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Default()
+			m := NewIdempotencyMiddleware(cancellableStore{Store: idempotency.NewMemoryStore(cfg.Idempotency)}, cfg)
+
+			handled := 0
+			var disconnect context.CancelFunc
+			handler := m.Wrap(func(rw http.ResponseWriter, r *http.Request) {
+				handled++
+				rw.WriteHeader(tc.status)
+				_, _ = rw.Write([]byte(`{}`))
+				disconnect()
+			})
+
+			send := func() *httptest.ResponseRecorder {
+				ctx, cancel := context.WithCancel(context.Background())
+				disconnect = cancel
+				defer cancel()
+
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(`{"text":"hello"}`)).WithContext(ctx)
+				req.Header.Set("System-Design-User-Id", "aa")
+				req.Header.Set(idempotencyKeyHeader, "key-1")
+
+				rec := httptest.NewRecorder()
+				handler(rec, req)
+				return rec
+			}
+
+			send()
+			retry := send()
+
+			if handled != tc.wantHandled {
+				t.Fatalf("request was handled %d times, want %d", handled, tc.wantHandled)
+			}
+			if retry.Code != tc.wantStatus {
+				t.Fatalf("got status %d of the retry, want %d", retry.Code, tc.wantStatus)
+			}
+			if replayed := retry.Header().Get(idempotentReplayedHeader) == "true"; replayed != (tc.wantHandled == 1) {
+				t.Fatalf("retry is replayed: %v", replayed)
+			}
+		})
+	}
+}
+
+// TestIdempotencyBodyLimit checks that requests with a key are limited like other requests
+func TestIdempotencyBodyLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.Validation.MaxBodyBytes = 2 << 20
+	m := NewIdempotencyMiddleware(idempotency.NewMemoryStore(cfg.Idempotency), cfg)
+
+	handler := m.Wrap(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	for _, tc := range []struct {
+		name   string
+		size   int
+		status int
+	}{
+		{name: "OverOneMebibyte", size: 3 << 19, status: http.StatusOK},
+		{name: "AboveConfiguredLimit", size: 2<<20 + 1, status: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(strings.Repeat("a", tc.size)))
+			req.Header.Set("System-Design-User-Id", "aa")
+			req.Header.Set(idempotencyKeyHeader, tc.name)
+
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tc.status {
+				t.Fatalf("got status %d, want %d", rec.Code, tc.status)
+			}
+		})
+	}
+}
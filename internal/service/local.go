@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"microblog/internal/config"
+	"microblog/internal/metrics"
+	"microblog/internal/model"
+	"sync"
+)
+
+var _ TaskProducer = (*LocalProducer)(nil)
+
+var errLocalQueueClosed = errors.New("local task queue is closed")
+
+// LocalProducer processes tasks in the process itself: through the queue in ALL mode, or in background goroutines
+// of the server with memory storage. Failed tasks are logged by the consumer and not retried
+type LocalProducer struct {
+	consumer *Consumer
+	// queue is nil if every task is run in its own goroutine
+	queue *LocalQueue
+}
+
+func NewLocalProducer(consumer *Consumer, queue *LocalQueue) *LocalProducer {
+	return &LocalProducer{consumer: consumer, queue: queue}
+}
+
+func (p *LocalProducer) SendPostTask(ctx context.Context, post model.Post) error {
+	serialized, _ := json.Marshal(post)
+
+	return p.run(ctx, func(ctx context.Context) error {
+		_, err := p.consumer.StreamNewPost(ctx, string(serialized))
+		return err
+	})
+}
+
+func (p *LocalProducer) SendFeedTask(ctx context.Context, from, to model.UserId) error {
+	return p.run(ctx, func(ctx context.Context) error {
+		_, err := p.consumer.RebuildFeed(ctx, string(from), string(to))
+		return err
+	})
+}
+
+func (p *LocalProducer) SendFeedCleanupTask(ctx context.Context, owner model.UserId, ids []model.PostId) error {
+	serialized, _ := json.Marshal(ids)
+
+	return p.run(ctx, func(ctx context.Context) error {
+		_, err := p.consumer.CleanFeed(ctx, string(owner), string(serialized))
+		return err
+	})
+}
+
+// run detaches the task from the request, keeping its trace and request id
+func (p *LocalProducer) run(ctx context.Context, task func(ctx context.Context) error) error {
+	if p.queue != nil {
+		return p.queue.push(ctx, task)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		_ = task(ctx)
+	}()
+	return nil
+}
+
+// LocalQueue passes tasks of the server to the worker loop of the same process in ALL mode.
+// Producers wait while the queue is full, tasks queued before Close are processed before it returns
+type LocalQueue struct {
+	tasks chan localTask
+	// slots limits the number of tasks processed at once, it is nil if the concurrency is unlimited
+	slots chan struct{}
+
+	mu        sync.RWMutex
+	closed    bool
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+type localTask struct {
+	ctx context.Context
+	run func(ctx context.Context) error
+}
+
+// StartLocalQueue starts the worker loop
+func StartLocalQueue(cfg config.QueueConfig) *LocalQueue {
+	q := &LocalQueue{
+		tasks: make(chan localTask, cfg.LocalSize),
+		done:  make(chan struct{}),
+	}
+	if cfg.Concurrency > 0 {
+		q.slots = make(chan struct{}, cfg.Concurrency)
+	}
+
+	q.wg.Add(1)
+	go q.loop()
+
+	return q
+}
+
+func (q *LocalQueue) loop() {
+	defer q.wg.Done()
+
+	for task := range q.tasks {
+		metrics.SetLocalQueueLength(len(q.tasks))
+
+		if q.slots != nil {
+			q.slots <- struct{}{}
+		}
+		q.wg.Add(1)
+		go func(task localTask) {
+			defer q.wg.Done()
+			if q.slots != nil {
+				defer func() { <-q.slots }()
+			}
+			_ = task.run(task.ctx)
+		}(task)
+	}
+}
+
+func (q *LocalQueue) push(ctx context.Context, run func(ctx context.Context) error) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return errLocalQueueClosed
+	}
+
+	select {
+	case q.tasks <- localTask{ctx: context.WithoutCancel(ctx), run: run}:
+		metrics.SetLocalQueueLength(len(q.tasks))
+		return nil
+	case <-q.done:
+		return errLocalQueueClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close rejects new tasks and waits until queued ones are processed
+func (q *LocalQueue) Close() {
+	q.closeOnce.Do(func() {
+		// producers waiting for room give up first, so they release the lock
+		close(q.done)
+
+		q.mu.Lock()
+		q.closed = true
+		close(q.tasks)
+		q.mu.Unlock()
+	})
+
+	q.wg.Wait()
+}
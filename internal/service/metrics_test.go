@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/common/expfmt"
+	"microblog/internal/config"
+	"microblog/internal/repo"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// scrape reads counters from /metrics keyed by the metric name with sorted labels, e.g. `name{code="200",route="/"}`
+func (h *harness) scrape() map[string]float64 {
+	h.t.Helper()
+
+	resp := h.do(http.MethodGet, "/metrics", "", nil)
+	if resp.status != http.StatusOK {
+		h.t.Fatalf("got status %d of /metrics", resp.status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(string(resp.body)))
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	counters := make(map[string]float64)
+	for name, family := range families {
+		for _, metric := range family.GetMetric() {
+			if metric.GetCounter() == nil {
+				continue
+			}
+			labels := make([]string, 0, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, label.GetName()+`="`+label.GetValue()+`"`)
+			}
+			sort.Strings(labels)
+			counters[name+"{"+strings.Join(labels, ",")+"}"] = metric.GetCounter().GetValue()
+		}
+	}
+	return counters
+}
+
+func TestMetrics(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := config.Default()
+	cfg.Redis.Addr = server.Addr()
+	cached, err := repo.NewRedisRepository(context.Background(), cfg, repo.NewMemoryRepository())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cached.Close() })
+
+	h := newHarness(t, withRepository(cached))
+	post := h.createPost("aa", "hello")
+	server.FlushAll()
+
+	before := h.scrape()
+	h.do(http.MethodGet, "/api/v1/posts/"+string(post.Id), "", nil).decode(t, http.StatusOK, nil)
+	h.do(http.MethodGet, "/api/v1/posts/"+string(post.Id), "", nil).decode(t, http.StatusOK, nil)
+	h.do(http.MethodGet, "/api/v1/posts/missing", "", nil).errorCode(t, http.StatusNotFound)
+	after := h.scrape()
+
+	route := `route="/api/v1/posts/{postId:[A-Za-z0-9_\-]+}"`
+	for _, tc := range []struct {
+		series string
+		want   float64
+	}{
+		{series: `microblog_http_requests_total{code="200",method="GET",` + route + `}`, want: 2},
+		{series: `microblog_http_requests_total{code="404",method="GET",` + route + `}`, want: 1},
+		// the first read misses the flushed cache and fills it for the second one, the missing post is a miss too
+		{series: `microblog_cache_requests_total{family="post",result="miss"}`, want: 2},
+		{series: `microblog_cache_requests_total{family="post",result="hit"}`, want: 1},
+	} {
+		if got := after[tc.series] - before[tc.series]; got != tc.want {
+			t.Errorf("%s increased by %v, want %v", tc.series, got, tc.want)
+		}
+	}
+
+	// routes are labeled by templates, so the number of series doesn't grow with ids
+	for series := range after {
+		if strings.Contains(series, string(post.Id)) || strings.Contains(series, "missing") {
+			t.Errorf("series %s is labeled with a raw path", series)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"github.com/gorilla/mux"
+	"log/slog"
+	"microblog/internal/logging"
+	"microblog/internal/metrics"
+	"microblog/internal/utils"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const requestIdHeader = "X-Request-Id"
+
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,128}$`)
+
+// requestIdMiddleware propagates X-Request-Id of the client (or generates a new one) to the response and to the context
+func requestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIdHeader)
+		if !requestIdPattern.MatchString(id) {
+			id = utils.UUID()
+		}
+
+		rw.Header().Set(requestIdHeader, id)
+		next.ServeHTTP(rw, r.WithContext(logging.WithRequestId(r.Context(), id)))
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// metricsMiddleware records count and latency of requests labeled by the route template and logs them
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		metrics.ObserveHTTPRequest(route, r.Method, recorder.status, start)
+
+		slog.DebugContext(r.Context(), "Handled HTTP request",
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.Int("status", recorder.status),
+			slog.Duration("duration", time.Since(start)))
+	})
+}
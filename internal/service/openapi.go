@@ -0,0 +1,236 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"io"
+	"log/slog"
+	"microblog/api"
+	"microblog/internal/config"
+	"microblog/internal/model"
+	"microblog/internal/validation"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const specPath = "/api/openapi.yaml"
+
+// kin-openapi reports read only properties as plain errors, not as schema errors
+var readOnlyPropertyPattern = regexp.MustCompile(`readOnly property "([^"]+)" in request`)
+
+// OpenAPIValidator checks requests and (optionally) responses of the routes described in api/api.yaml.
+// Requests of routes missing in the specification are passed as is
+type OpenAPIValidator struct {
+	router            routers.Router
+	validateRequests  bool
+	validateResponses bool
+	maxBodyBytes      int64
+	options           *openapi3filter.Options
+}
+
+func NewOpenAPIValidator(cfg config.Config) (*OpenAPIValidator, error) {
+	doc, err := api.Load()
+	if err != nil {
+		return nil, err
+	}
+	// servers are not listed in the specification, the service may be reached by any host
+	doc.Servers = nil
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build api spec router: %w", err)
+	}
+
+	return &OpenAPIValidator{
+		router:            router,
+		validateRequests:  cfg.OpenAPI.ValidateRequests,
+		validateResponses: cfg.OpenAPI.ValidateResponses,
+		maxBodyBytes:      int64(cfg.Validation.MaxBodyBytes),
+		options: &openapi3filter.Options{
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+			IncludeResponseStatus: true,
+			// defaults are applied by handlers, e.g. page size is configurable
+			SkipSettingDefaults: true,
+		},
+	}, nil
+}
+
+func (v *OpenAPIValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := v.router.FindRoute(r)
+		// the spec itself is yaml, kin-openapi can't validate it as a string
+		if err != nil || route.Path == specPath {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    v.options,
+		}
+
+		if v.validateRequests {
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(rw, r.Body, v.maxBodyBytes)
+				if r.Header.Get("Content-Type") == "" {
+					r.Header.Set("Content-Type", "application/json")
+				}
+			}
+
+			if err = openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+				writeError(rw, r, requestValidationError(err))
+				return
+			}
+		}
+
+		if !v.validateResponses {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		recorder := &bufferedResponse{header: make(http.Header)}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 recorder.status,
+			Header:                 recorder.header,
+			Body:                   io.NopCloser(bytes.NewReader(recorder.body.Bytes())),
+			Options:                v.options,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Response does not match the api spec",
+				slog.Int("status", recorder.status),
+				slog.String("body", recorder.body.String()),
+				slog.Any("error", err))
+			writeError(rw, r, err)
+			return
+		}
+
+		recorder.flush(rw)
+	})
+}
+
+// requestValidationError translates spec violations into the same errors handlers return for them
+func requestValidationError(err error) error {
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return &model.ValidationError{Fields: []model.FieldError{{Code: validation.CodeMalformed, Message: err.Error()}}}
+	}
+
+	if param := requestErr.Parameter; param != nil {
+		switch {
+		case param.In == openapi3.ParameterInHeader && param.Name == "System-Design-User-Id":
+			return model.InvalidUserId
+		case param.In == openapi3.ParameterInHeader && param.Name == idempotencyKeyHeader:
+			return model.InvalidIdempotencyKey
+		case param.In == openapi3.ParameterInQuery && param.Name == "size":
+			return model.InvalidPageSize
+		case param.In == openapi3.ParameterInQuery && param.Name == "page":
+			return model.InvalidPageToken
+		case param.In == openapi3.ParameterInQuery && (param.Name == "since" || param.Name == "before"):
+			return model.InvalidPageCursor
+		case param.In == openapi3.ParameterInPath:
+			return model.RouteNotFound
+		}
+
+		return &model.ValidationError{Fields: []model.FieldError{{
+			Field:   param.Name,
+			Code:    validation.CodeSchemaMismatch,
+			Message: requestErr.Reason,
+		}}}
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &model.ValidationError{Fields: []model.FieldError{{
+			Code:    validation.CodeTooLarge,
+			Message: fmt.Sprintf("The body must not be larger than %d bytes", maxBytesErr.Limit),
+		}}}
+	}
+
+	if match := readOnlyPropertyPattern.FindStringSubmatch(err.Error()); match != nil {
+		return &model.ValidationError{Fields: []model.FieldError{{
+			Field:   match[1],
+			Code:    validation.CodeReadOnly,
+			Message: "The field is set by the server",
+		}}}
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		return &model.ValidationError{Fields: []model.FieldError{{
+			Field:   strings.Join(schemaErr.JSONPointer(), "."),
+			Code:    schemaErrorCode(schemaErr),
+			Message: schemaErr.Reason,
+		}}}
+	}
+
+	return &model.ValidationError{Fields: []model.FieldError{{Code: validation.CodeMalformed, Message: requestErr.Error()}}}
+}
+
+func schemaErrorCode(err *openapi3.SchemaError) string {
+	switch err.SchemaField {
+	case "type", "nullable":
+		return validation.CodeInvalidType
+	case "required", "minLength":
+		return validation.CodeRequired
+	case "maxLength":
+		return validation.CodeTooLong
+	case "pattern":
+		return validation.CodePatternMismatch
+	case "readOnly":
+		return validation.CodeReadOnly
+	default:
+		return validation.CodeSchemaMismatch
+	}
+}
+
+// bufferedResponse holds the response until it is validated
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) flush(rw http.ResponseWriter) {
+	for k, v := range b.header {
+		rw.Header()[k] = v
+	}
+	rw.WriteHeader(b.status)
+	_, _ = rw.Write(b.body.Bytes())
+}
+
+// serveSpec responds with the specification the server is validated against
+func serveSpec(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "application/yaml")
+	_, _ = rw.Write(api.Spec)
+}
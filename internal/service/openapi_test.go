@@ -0,0 +1,88 @@
+package service
+
+import (
+	"bytes"
+	"microblog/api"
+	"microblog/internal/config"
+	"microblog/internal/model"
+	"microblog/internal/validation"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeSpec(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.do(http.MethodGet, specPath, "", nil)
+	if resp.status != http.StatusOK || !bytes.Equal(resp.body, api.Spec) {
+		t.Fatalf("got status %d and %d bytes, want the spec", resp.status, len(resp.body))
+	}
+	if ct := resp.header.Get("Content-Type"); ct != "application/yaml" {
+		t.Fatalf("got content type %q", ct)
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	h := newHarness(t)
+
+	var body ErrorResponse
+	h.do(http.MethodPost, "/api/v1/posts", "aa", map[string]string{"text": "hello", "id": "forged"}).
+		decode(t, http.StatusBadRequest, &body)
+	if len(body.Error.Fields) != 1 || body.Error.Fields[0].Field != "id" || body.Error.Fields[0].Code != validation.CodeReadOnly {
+		t.Fatalf("got %+v, want read only error of id", body.Error)
+	}
+
+	h.do(http.MethodPost, "/api/v1/posts", "aa", map[string]int{"text": 1}).decode(t, http.StatusBadRequest, &body)
+	if len(body.Error.Fields) != 1 || body.Error.Fields[0].Field != "text" || body.Error.Fields[0].Code != validation.CodeInvalidType {
+		t.Fatalf("got %+v, want type error of text", body.Error)
+	}
+
+	if code := h.do(http.MethodGet, "/api/v1/feed", "", nil).errorCode(t, http.StatusUnauthorized); code != model.InvalidUserId.Error() {
+		t.Fatalf("got code %q for missing user id", code)
+	}
+
+	// the content type is optional for backward compatibility
+	resp := h.do(http.MethodPost, "/api/v1/posts", "aa", nil, "Content-Type", "")
+	if code := resp.errorCode(t, http.StatusBadRequest); code != model.InvalidRequestBody.Error() {
+		t.Fatalf("got code %q for an empty body", code)
+	}
+}
+
+func TestResponseValidation(t *testing.T) {
+	cfg := config.Default()
+	cfg.OpenAPI.ValidateResponses = true
+
+	validator, err := NewOpenAPIValidator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		status int
+		body   string
+		want   int
+	}{
+		{name: "Valid", status: http.StatusOK, body: `{"posts":[]}`, want: http.StatusOK},
+		{name: "WrongType", status: http.StatusOK, body: `{"posts":5}`, want: http.StatusInternalServerError},
+		{name: "UndocumentedStatus", status: http.StatusTeapot, body: `{}`, want: http.StatusInternalServerError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := validator.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("Content-Type", "application/json")
+				rw.WriteHeader(tc.status)
+				_, _ = rw.Write([]byte(tc.body))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/feed", nil)
+			req.Header.Set("System-Design-User-Id", "aa")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.want {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tc.want, rec.Body)
+			}
+		})
+	}
+}
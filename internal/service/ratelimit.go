@@ -0,0 +1,94 @@
+package service
+
+import (
+	"log/slog"
+	"math"
+	"microblog/internal/config"
+	"microblog/internal/model"
+	"microblog/internal/ratelimit"
+	"microblog/internal/utils"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	rateLimitClassRead  = "read"
+	rateLimitClassWrite = "write"
+)
+
+type RateLimitMiddleware struct {
+	limiter    ratelimit.Limiter
+	read       ratelimit.Limit
+	write      ratelimit.Limit
+	trustProxy bool
+}
+
+func NewRateLimitMiddleware(limiter ratelimit.Limiter, cfg config.RateLimitConfig) *RateLimitMiddleware {
+	return &RateLimitMiddleware{
+		limiter:    limiter,
+		read:       ratelimit.Limit{Rate: cfg.ReadRate, Burst: cfg.ReadBurst},
+		write:      ratelimit.Limit{Rate: cfg.WriteRate, Burst: cfg.WriteBurst},
+		trustProxy: cfg.TrustProxy,
+	}
+}
+
+// Middleware limits API requests per authorized user, or per client IP for requests without user id.
+// Reads and writes have separate buckets. If the limiter is unavailable, requests are let through
+func (m *RateLimitMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		class, limit := rateLimitClassWrite, m.write
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			class, limit = rateLimitClassRead, m.read
+		}
+
+		result, err := m.limiter.Allow(r.Context(), utils.CreateRedisKeyForRateLimit(class, m.subject(r)), limit)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Rate limiter is unavailable", slog.Any("error", err))
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		rw.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		rw.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		rw.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			rw.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			writeError(rw, r, model.RateLimitExceeded)
+			return
+		}
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (m *RateLimitMiddleware) subject(r *http.Request) string {
+	if userId, err := utils.GetAuthorizedUserId(r); err == nil {
+		return "user:" + string(userId)
+	}
+
+	if m.trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ip, _, _ := strings.Cut(forwarded, ",")
+			return "ip:" + strings.TrimSpace(ip)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/RichardKnop/machinery/v1/tasks"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"microblog/internal/config"
+	"microblog/internal/logging"
+	"microblog/internal/model"
+	"microblog/internal/repo"
+	"testing"
+)
+
+// recordSpans installs a global tracer provider which keeps ended spans in memory until the test ends
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+		_ = provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+func findSpan(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("span %q was not recorded", name)
+	return nil
+}
+
+// assertChildOf checks that the span continues the trace of the parent, under the parent or its descendant
+func assertChildOf(t *testing.T, spans []sdktrace.ReadOnlySpan, span, parent sdktrace.ReadOnlySpan) {
+	t.Helper()
+
+	if span.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Fatalf("span %q has trace %s, want trace %s of %q", span.Name(), span.SpanContext().TraceID(),
+			parent.SpanContext().TraceID(), parent.Name())
+	}
+
+	ids := make(map[trace.SpanID]sdktrace.ReadOnlySpan, len(spans))
+	for _, s := range spans {
+		ids[s.SpanContext().SpanID()] = s
+	}
+	for current := span; current.Parent().IsValid(); {
+		if current.Parent().SpanID() == parent.SpanContext().SpanID() {
+			return
+		}
+		next, ok := ids[current.Parent().SpanID()]
+		if !ok {
+			break
+		}
+		current = next
+	}
+	t.Fatalf("span %q is not a descendant of %q", span.Name(), parent.Name())
+}
+
+func TestTaskTracing(t *testing.T) {
+	t.Run("LocalProducer", func(t *testing.T) {
+		recorder := recordSpans(t)
+
+		cfg := config.Default()
+		r := repo.NewMemoryRepository()
+		queue := StartLocalQueue(cfg.Queue)
+		h := newHarness(t, withRepository(r), withProducer(NewLocalProducer(NewConsumer(r, cfg.Queue), queue)))
+
+		h.subscribe("bb", "aa")
+		h.createPost("aa", "hello")
+		queue.Close()
+
+		spans := recorder.Ended()
+		subscribe := findSpan(t, spans, "/api/v1/users/{userId:[0-9a-f]+}/subscribe")
+		create := findSpan(t, spans, "/api/v1/posts")
+		if subscribe.SpanContext().TraceID() == create.SpanContext().TraceID() {
+			t.Fatal("requests share a trace")
+		}
+
+		assertChildOf(t, spans, findSpan(t, spans, "task.process.rebuildFeed"), subscribe)
+		assertChildOf(t, spans, findSpan(t, spans, "task.process.streamNewPost"), create)
+	})
+
+	// the worker receives trace context only through headers of the task signature
+	t.Run("TaskHeaders", func(t *testing.T) {
+		recorder := recordSpans(t)
+
+		ctx, request := otel.Tracer("test").Start(logging.WithRequestId(context.Background(), "request-1"), "request")
+		headers := taskHeaders(ctx)
+		request.End()
+
+		serialized, _ := json.Marshal(model.Post{Id: "65f1c0de0000000000000001", AuthorId: "aa", Text: "hello"})
+		task, err := tasks.NewWithSignature(NewConsumer(repo.NewMemoryRepository(), config.Default().Queue).StreamNewPost,
+			&tasks.Signature{
+				Name:    "streamNewPost",
+				Args:    []tasks.Arg{{Name: "serialized", Type: "string", Value: string(serialized)}},
+				Headers: headers,
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = task.Call(); err != nil {
+			t.Fatal(err)
+		}
+
+		spans := recorder.Ended()
+		process := findSpan(t, spans, "task.process.streamNewPost")
+		if !process.Parent().IsRemote() {
+			t.Fatal("task span doesn't continue the trace from headers")
+		}
+		assertChildOf(t, spans, process, findSpan(t, spans, "request"))
+		if headers[requestIdHeader] != "request-1" {
+			t.Fatalf("got request id %v in headers, want request-1", headers[requestIdHeader])
+		}
+	})
+}
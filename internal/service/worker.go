@@ -3,97 +3,195 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/RichardKnop/machinery/v1"
-	"github.com/RichardKnop/machinery/v1/config"
-	"github.com/RichardKnop/machinery/v1/log"
+	machineryconfig "github.com/RichardKnop/machinery/v1/config"
+	machinerylog "github.com/RichardKnop/machinery/v1/log"
 	"github.com/RichardKnop/machinery/v1/tasks"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"microblog/internal/config"
+	"microblog/internal/logging"
+	"microblog/internal/metrics"
 	"microblog/internal/model"
 	"microblog/internal/repo"
-	"os"
+	"microblog/internal/tracing"
+	"microblog/internal/utils"
+	"net/http"
+	"time"
 )
 
 type Consumer struct {
-	repo repo.Repository
+	repo          repo.Repository
+	drainPageSize int
 }
 
+func NewConsumer(r repo.Repository, cfg config.QueueConfig) *Consumer {
+	return &Consumer{repo: r, drainPageSize: cfg.DrainPageSize}
+}
+
+// TaskProducer schedules background processing of new posts, subscriptions and feed cleanup
+type TaskProducer interface {
+	SendPostTask(ctx context.Context, post model.Post) error
+	SendFeedTask(ctx context.Context, from, to model.UserId) error
+	// SendFeedCleanupTask removes feed items which point to posts that no longer exist
+	SendFeedCleanupTask(ctx context.Context, owner model.UserId, ids []model.PostId) error
+}
+
+var _ TaskProducer = (*Producer)(nil)
+
 type Producer struct {
 	repo   repo.Repository
 	server *machinery.Server
 }
 
-func StartProducer(r repo.Repository) (Producer, error) {
-	producer := Producer{repo: r}
-	server, err := startServer(r)
+func StartProducer(cfg config.Config, r repo.Repository) (*Producer, error) {
+	producer := &Producer{repo: r}
+	server, err := startServer(cfg, r)
 	producer.server = server
 	return producer, err
 }
 
-func StartConsumer(r repo.Repository) error {
-	log.INFO.Printf("Starting worker...")
-
-	consumerTag := "machinery_worker"
+func StartConsumer(cfg config.Config, r repo.Repository) error {
+	slog.Info("Starting worker...")
 
-	server, err := startServer(r)
+	server, err := startServer(cfg, r)
 	if err != nil {
 		return err
 	}
 
-	worker := server.NewWorker(consumerTag, 0)
+	worker := server.NewWorker(cfg.Queue.ConsumerTag, cfg.Queue.Concurrency)
 
 	errorhandler := func(err error) {
-		log.ERROR.Println("Something went wrong:", err)
+		slog.Error("Something went wrong", slog.Any("error", err))
 	}
 
 	worker.SetErrorHandler(errorhandler)
 
+	brokerCheck, closeBrokerCheck := newBrokerHealthCheck(cfg)
+	defer closeBrokerCheck()
+
+	healthSrv := newHealthServer(cfg, NewHealthHandler(cfg.Health, append(r.HealthChecks(), brokerCheck)))
+	go func() {
+		slog.Info("Start serving maintenance HTTP", slog.String("addr", healthSrv.Addr))
+		if err := healthSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Maintenance HTTP server failed", slog.Any("error", err))
+		}
+	}()
+	defer healthSrv.Close()
+
 	return worker.Launch()
 }
 
-func startServer(r repo.Repository) (*machinery.Server, error) {
-	url, ok := os.LookupEnv("REDIS_URL")
-
-	if !ok {
-		url = "redis://localhost:6379"
-	} else {
-		url = "redis://" + url
-	}
+func startServer(cfg config.Config, r repo.Repository) (*machinery.Server, error) {
+	url := cfg.Redis.BrokerURL()
+	q := cfg.Queue
 
-	cnf := &config.Config{
-		DefaultQueue:    "machinery_tasks",
-		ResultsExpireIn: 3600,
+	cnf := &machineryconfig.Config{
+		DefaultQueue:    q.Name,
+		ResultsExpireIn: int(q.ResultsExpireIn.Seconds()),
 		Broker:          url,
 		ResultBackend:   url,
-		Redis: &config.RedisConfig{
-			MaxIdle:                3,
-			IdleTimeout:            240,
-			ReadTimeout:            15,
-			WriteTimeout:           15,
-			ConnectTimeout:         15,
-			NormalTasksPollPeriod:  1000,
-			DelayedTasksPollPeriod: 500,
+		Redis: &machineryconfig.RedisConfig{
+			MaxIdle:                q.MaxIdle,
+			IdleTimeout:            int(q.IdleTimeout.Seconds()),
+			ReadTimeout:            int(q.ReadTimeout.Seconds()),
+			WriteTimeout:           int(q.WriteTimeout.Seconds()),
+			ConnectTimeout:         int(q.ConnectTimeout.Seconds()),
+			NormalTasksPollPeriod:  int(q.NormalTasksPollPeriod.Milliseconds()),
+			DelayedTasksPollPeriod: int(q.DelayedTasksPollPeriod.Milliseconds()),
 		},
 	}
 
+	machinerylog.SetDebug(logging.MachineryLogger{Level: slog.LevelDebug})
+	machinerylog.SetInfo(logging.MachineryLogger{Level: slog.LevelInfo})
+	machinerylog.SetWarning(logging.MachineryLogger{Level: slog.LevelWarn})
+	machinerylog.SetError(logging.MachineryLogger{Level: slog.LevelError})
+	machinerylog.SetFatal(logging.MachineryLogger{Level: slog.LevelError})
+
 	server, err := machinery.NewServer(cnf)
 	if err != nil {
 		return nil, err
 	}
 
-	consumer := Consumer{
-		repo: r,
-	}
+	consumer := NewConsumer(r, q)
 
 	// Register tasks
 	t := map[string]interface{}{
 		"streamNewPost": consumer.StreamNewPost,
 		"rebuildFeed":   consumer.RebuildFeed,
+		"cleanFeed":     consumer.CleanFeed,
 	}
 
 	return server, server.RegisterTasks(t)
 }
 
+// send propagates trace context of the request through task headers, so consumer spans belong to the same trace
+func (p *Producer) send(ctx context.Context, task *tasks.Signature) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "task.send."+task.Name,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("task.name", task.Name)))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	task.Headers = taskHeaders(ctx)
+	_, err = p.server.SendTaskWithContext(ctx, task)
+	if err != nil {
+		return fmt.Errorf("could not send task: %w", err)
+	}
+
+	return nil
+}
+
+// taskHeaders carries trace context and request id of the request to the consumer of the task
+func taskHeaders(ctx context.Context) tasks.Headers {
+	headers := tasks.Headers{}
+	otel.GetTextMapPropagator().Inject(ctx, taskHeadersCarrier(headers))
+	if id := logging.RequestId(ctx); id != "" {
+		headers[requestIdHeader] = id
+	}
+	return headers
+}
+
+// startTaskSpan continues the trace and carries request id of the request which produced the task
+func startTaskSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if signature := tasks.SignatureFromContext(ctx); signature != nil {
+		carrier := taskHeadersCarrier(signature.Headers)
+		ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+		if id := carrier.Get(requestIdHeader); id != "" {
+			ctx = logging.WithRequestId(ctx, id)
+		}
+	}
+
+	return tracing.Tracer().Start(ctx, "task.process."+name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("task.name", name)))
+}
+
+// taskHeadersCarrier adapts machinery task headers to otel propagation.TextMapCarrier
+type taskHeadersCarrier tasks.Headers
+
+func (c taskHeadersCarrier) Get(key string) string {
+	v, _ := c[key].(string)
+	return v
+}
+
+func (c taskHeadersCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c taskHeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
 func (p *Producer) SendPostTask(ctx context.Context, post model.Post) error {
 	serialized, _ := json.Marshal(post)
 
@@ -108,12 +206,7 @@ func (p *Producer) SendPostTask(ctx context.Context, post model.Post) error {
 		},
 	}
 
-	_, err := p.server.SendTaskWithContext(ctx, &task)
-	if err != nil {
-		return fmt.Errorf("could not send task: %s", err.Error())
-	}
-
-	return nil
+	return p.send(ctx, &task)
 }
 
 func (p *Producer) SendFeedTask(ctx context.Context, from, to model.UserId) error {
@@ -133,32 +226,59 @@ func (p *Producer) SendFeedTask(ctx context.Context, from, to model.UserId) erro
 		},
 	}
 
-	_, err := p.server.SendTaskWithContext(ctx, &task)
-	if err != nil {
-		return fmt.Errorf("could not send task: %s", err.Error())
+	return p.send(ctx, &task)
+}
+
+func (p *Producer) SendFeedCleanupTask(ctx context.Context, owner model.UserId, ids []model.PostId) error {
+	serialized, _ := json.Marshal(ids)
+
+	task := tasks.Signature{
+		Name: "cleanFeed",
+		Args: []tasks.Arg{
+			{
+				Name:  "feedOwner",
+				Type:  "string",
+				Value: string(owner),
+			},
+			{
+				Name:  "serializedIds",
+				Type:  "string",
+				Value: string(serialized),
+			},
+		},
 	}
 
-	return nil
+	return p.send(ctx, &task)
 }
 
-func (c *Consumer) StreamNewPost(serialized string) (string, error) {
+func (c *Consumer) StreamNewPost(ctx context.Context, serialized string) (status string, err error) {
+	ctx, span := startTaskSpan(ctx, "streamNewPost")
+	defer func(start time.Time) {
+		metrics.ObserveTask("streamNewPost", start, err)
+		tracing.End(span, err)
+	}(time.Now())
+
 	var post model.Post
 	_ = json.Unmarshal([]byte(serialized), &post)
 
-	// because json ignores token field
-	post.Token, _ = primitive.ObjectIDFromHex(string(post.Id))
+	utils.RestorePostToken(&post)
+
+	span.SetAttributes(attribute.String("post.id", string(post.Id)))
 
-	followers, err := c.repo.GetSubscribers(context.Background(), post.AuthorId)
+	followers, err := c.repo.GetSubscribers(ctx, post.AuthorId)
 	if err != nil {
-		log.ERROR.Println(err.Error())
+		slog.ErrorContext(ctx, "Failed to get followers", slog.String("author_id", string(post.AuthorId)), slog.Any("error", err))
 		return "get followers", err
 	}
 
+	metrics.ObserveFanOut("streamNewPost", len(followers))
+	span.SetAttributes(attribute.Int("task.fan_out", len(followers)))
+
 	for _, follower := range followers {
 		metadata := model.FeedMetadataDocument{UserId: follower, PostId: post.Id, Token: post.Token}
-		err = c.repo.AddPostToFeed(context.Background(), metadata)
+		err = c.repo.AddPostToFeed(ctx, metadata)
 		if err != nil {
-			log.ERROR.Println(err.Error())
+			slog.ErrorContext(ctx, "Failed to update feed", slog.String("feed_owner", string(metadata.UserId)), slog.Any("error", err))
 			return "update feed", err
 		}
 	}
@@ -166,21 +286,30 @@ func (c *Consumer) StreamNewPost(serialized string) (string, error) {
 	return "done", nil
 }
 
-func (c *Consumer) RebuildFeed(feedOwner, newSource string) (string, error) {
-	log.INFO.Printf("user %s subscribed for user %s. Rebuilding feed....", feedOwner, newSource)
+func (c *Consumer) RebuildFeed(ctx context.Context, feedOwner, newSource string) (status string, err error) {
+	ctx, span := startTaskSpan(ctx, "rebuildFeed")
+	defer func(start time.Time) {
+		metrics.ObserveTask("rebuildFeed", start, err)
+		tracing.End(span, err)
+	}(time.Now())
+
+	slog.InfoContext(ctx, "Rebuilding feed after subscription", slog.String("feed_owner", feedOwner), slog.String("new_source", newSource))
 
-	posts, err := drainFullPostPage(c.repo, model.UserId(newSource))
+	posts, err := drainFullPostPage(ctx, c.repo, model.UserId(newSource), c.drainPageSize)
 
 	if err != nil {
-		log.ERROR.Println(err.Error())
+		slog.ErrorContext(ctx, "Failed to get posts of new source", slog.String("new_source", newSource), slog.Any("error", err))
 		return "posts", err
 	}
 
+	metrics.ObserveFanOut("rebuildFeed", len(posts))
+	span.SetAttributes(attribute.Int("task.fan_out", len(posts)))
+
 	for _, post := range posts {
 		metadata := model.FeedMetadataDocument{UserId: model.UserId(feedOwner), PostId: post.Id, Token: post.Token}
-		err = c.repo.AddPostToFeed(context.Background(), metadata)
+		err = c.repo.AddPostToFeed(ctx, metadata)
 		if err != nil {
-			log.ERROR.Println(err.Error())
+			slog.ErrorContext(ctx, "Failed to update feed", slog.String("feed_owner", string(metadata.UserId)), slog.Any("error", err))
 			return "update feed", err
 		}
 	}
@@ -188,20 +317,66 @@ func (c *Consumer) RebuildFeed(feedOwner, newSource string) (string, error) {
 	return "done", nil
 }
 
-func drainFullPostPage(r repo.Repository, userId model.UserId) ([]model.Post, error) {
+// CleanFeed removes dangling feed items, posts are looked up again in case they were missing only for a moment
+func (c *Consumer) CleanFeed(ctx context.Context, feedOwner, serializedIds string) (status string, err error) {
+	ctx, span := startTaskSpan(ctx, "cleanFeed")
+	defer func(start time.Time) {
+		metrics.ObserveTask("cleanFeed", start, err)
+		tracing.End(span, err)
+	}(time.Now())
+
+	var ids []model.PostId
+	_ = json.Unmarshal([]byte(serializedIds), &ids)
+
+	posts, err := c.repo.GetPostsByIds(ctx, ids)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get posts of feed", slog.String("feed_owner", feedOwner), slog.Any("error", err))
+		return "posts", err
+	}
+
+	existing := make(map[model.PostId]bool, len(posts))
+	for _, post := range posts {
+		existing[post.Id] = true
+	}
+
+	var dangling []model.PostId
+	for _, id := range ids {
+		if !existing[id] {
+			dangling = append(dangling, id)
+		}
+	}
+
+	metrics.ObserveFanOut("cleanFeed", len(dangling))
+	span.SetAttributes(attribute.Int("task.fan_out", len(dangling)))
+
+	if len(dangling) == 0 {
+		return "done", nil
+	}
+
+	slog.InfoContext(ctx, "Removing dangling feed items", slog.String("feed_owner", feedOwner), slog.Int("count", len(dangling)))
+
+	err = c.repo.RemovePostsFromFeed(ctx, model.UserId(feedOwner), dangling)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to update feed", slog.String("feed_owner", feedOwner), slog.Any("error", err))
+		return "update feed", err
+	}
+
+	return "done", nil
+}
+
+func drainFullPostPage(ctx context.Context, r repo.Repository, userId model.UserId, size int) ([]model.Post, error) {
 	page := model.EmptyPage
-	size := 100
 
 	var result []model.Post
 	firstTry := true
 
 	for page != model.EmptyPage || firstTry {
 		firstTry = false
-		arr, newPage, err := r.GetPosts(context.Background(), userId, page, size)
+		arr, cursors, err := r.GetPosts(ctx, userId, model.PageRequest{Cursor: page, Direction: model.DirectionOlder, Size: size})
 		if err != nil {
 			return result, err
 		}
-		page = newPage
+		page = cursors.Older
 		result = append(result, arr...)
 	}
 
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Jitter randomly shortens or extends ttl by up to fraction of it, so entries written together don't expire together
+func Jitter(ttl time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration((rand.Float64()*2-1)*fraction*float64(ttl))
+}
+
+// RefreshEarly decides if a hit refreshes the entry before it expires. The probability grows from 0 at the start
+// of the window to 1 at expiration, so a hot entry is usually refreshed by a single request before it expires
+func RefreshEarly(remaining, window time.Duration) bool {
+	// negative values mean there is no ttl
+	if window <= 0 || remaining <= 0 || remaining >= window {
+		return false
+	}
+	return rand.Float64() >= float64(remaining)/float64(window)
+}
@@ -2,7 +2,8 @@ package utils
 
 import (
 	"encoding/json"
-	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"microblog/internal/config"
 	"microblog/internal/model"
 	"net/http"
 	"regexp"
@@ -14,7 +15,7 @@ func GetAuthorizedUserId(r *http.Request) (model.UserId, error) {
 	matched, err := regexp.Match(`[0-9a-f]+`, []byte(userId))
 
 	if !matched || err != nil {
-		return userId, fmt.Errorf("empty or invalid user id")
+		return userId, model.InvalidUserId
 	}
 
 	return userId, nil
@@ -29,25 +30,39 @@ func GetPageToken(r *http.Request) (model.PageToken, error) {
 		matched, err := regexp.Match(`[A-Za-z0-9_\-]+`, []byte(pageToken))
 
 		if !matched || err != nil {
-			return pageToken, fmt.Errorf("invalid page token")
+			return pageToken, model.InvalidPageToken
 		}
 	}
 
 	return pageToken, nil
 }
 
-func GetSize(r *http.Request) (int, error) {
+// GetPostCursor reads a post id used as a page cursor, post ids are storage cursors of the posts
+func GetPostCursor(r *http.Request, name string) (model.PageToken, error) {
+	value := r.URL.Query().Get(name)
+
+	if value == "" {
+		return model.EmptyPage, nil
+	}
+	if !primitive.IsValidObjectID(value) {
+		return model.EmptyPage, model.InvalidPageCursor
+	}
+
+	return model.PageToken(value), nil
+}
+
+func GetSize(r *http.Request, cfg config.PaginationConfig) (int, error) {
 	rSize := r.URL.Query().Get("size")
 	var size int
 
 	if rSize == "" {
-		size = 10
+		size = cfg.DefaultSize
 	} else {
 		var err error
 		size, err = strconv.Atoi(rSize)
 
-		if err != nil || size < 1 || size > 100 {
-			return size, fmt.Errorf("invalid size param")
+		if err != nil || size < 1 || size > cfg.MaxSize {
+			return size, model.InvalidPageSize
 		}
 	}
 
@@ -55,9 +70,13 @@ func GetSize(r *http.Request) (int, error) {
 }
 
 func WriteResponseBody(rw http.ResponseWriter, body any) {
+	WriteResponseBodyWithStatus(rw, http.StatusOK, body)
+}
+
+func WriteResponseBodyWithStatus(rw http.ResponseWriter, status int, body any) {
 	rawResponse, _ := json.Marshal(body)
 
 	rw.Header().Set("Content-Type", "application/json")
-	rw.WriteHeader(http.StatusOK)
+	rw.WriteHeader(status)
 	_, _ = rw.Write(rawResponse)
 }
@@ -3,6 +3,7 @@ package utils
 import (
 	"encoding/base64"
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"microblog/internal/model"
 	"time"
 )
@@ -19,3 +20,8 @@ func UUID() string {
 func CreateRandomPostId() model.PostId {
 	return model.PostId(base64.URLEncoding.EncodeToString([]byte(UUID())))
 }
+
+// RestorePostToken sets the token of a post decoded from JSON, where it is omitted, from its id
+func RestorePostToken(post *model.Post) {
+	post.Token, _ = primitive.ObjectIDFromHex(string(post.Id))
+}
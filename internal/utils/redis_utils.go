@@ -1,15 +1,26 @@
 package utils
 
-import "microblog/internal/model"
+import (
+	"microblog/internal/model"
+	"strconv"
+)
 
 func CreateRedisKeyForPost(postId model.PostId) string {
 	return "post:" + string(postId)
 }
 
-func CreateRedisKeyForPostPage(userId model.UserId) string {
+func CreateRedisKeyForPostTimeline(userId model.UserId) string {
 	return "posts:" + string(userId)
 }
 
+func CreateRedisKeyForPostsGeneration(userId model.UserId) string {
+	return "postsgen:" + string(userId)
+}
+
+func CreateRedisKeyForPostPage(userId model.UserId, generation string, page model.PageRequest) string {
+	return "postpages:" + string(userId) + ":" + generation + ":" + createPageKey(page)
+}
+
 func CreateRedisKeyForSubscribers(userId model.UserId) string {
 	return "subscribers:" + string(userId)
 }
@@ -18,6 +29,35 @@ func CreateRedisKeyForSubscriptions(userId model.UserId) string {
 	return "subscriptions:" + string(userId)
 }
 
-func CreateRedisKeyForFeedPage(userId model.UserId) string {
+func CreateRedisKeyForFeedGeneration(userId model.UserId) string {
+	return "feedgen:" + string(userId)
+}
+
+func CreateRedisKeyForFeedTimeline(userId model.UserId) string {
 	return "feeds:" + string(userId)
 }
+
+func CreateRedisKeyForFeedPage(userId model.UserId, generation string, page model.PageRequest) string {
+	return "feedpages:" + string(userId) + ":" + generation + ":" + createPageKey(page)
+}
+
+func CreateRedisKeyForHydratedFeedPage(userId model.UserId, generation string, page model.PageRequest) string {
+	return "feedposts:" + string(userId) + ":" + generation + ":" + createPageKey(page)
+}
+
+func CreateRedisKeyForIdempotency(userId model.UserId, key string) string {
+	return "idempotency:" + string(userId) + ":" + key
+}
+
+func CreateRedisKeyForRateLimit(class string, subject string) string {
+	return "ratelimit:" + class + ":" + subject
+}
+
+// createPageKey identifies a window of a list, pages without a cursor are the first page in both directions
+func createPageKey(page model.PageRequest) string {
+	direction := "older"
+	if page.Direction == model.DirectionNewer && page.Cursor != model.EmptyPage {
+		direction = "newer"
+	}
+	return direction + ":" + string(page.Cursor) + ":" + strconv.Itoa(page.Size)
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"microblog/internal/config"
+	"time"
+)
+
+// Retry runs op until it succeeds, doubling the pause between attempts up to cfg.MaxBackoff
+func Retry(ctx context.Context, cfg config.RetryConfig, name string, op func(ctx context.Context) error) error {
+	backoff := cfg.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil || attempt >= cfg.MaxAttempts {
+			return err
+		}
+
+		slog.WarnContext(ctx, "Attempt failed, retrying",
+			slog.String("operation", name),
+			slog.Int("attempt", attempt),
+			slog.Int("max_attempts", cfg.MaxAttempts),
+			slog.Duration("backoff", backoff),
+			slog.Any("error", err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > cfg.MaxBackoff {
+			backoff = cfg.MaxBackoff
+		}
+	}
+}
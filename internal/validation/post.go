@@ -0,0 +1,174 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+	"io"
+	"microblog/internal/config"
+	"microblog/internal/model"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	CodeMalformed       = "malformed"
+	CodeTooLarge        = "too_large"
+	CodeUnknownField    = "unknown_field"
+	CodeInvalidType     = "invalid_type"
+	CodeRequired        = "required"
+	CodeTooLong         = "too_long"
+	CodePatternMismatch = "pattern_mismatch"
+	CodeReadOnly        = "read_only"
+	CodeSchemaMismatch  = "schema_mismatch"
+	CodeInvalidEncoding = "invalid_encoding"
+)
+
+type PostValidator struct {
+	maxLength    int
+	maxBodyBytes int64
+	patterns     map[string]*regexp.Regexp
+}
+
+func NewPostValidator(cfg config.ValidationConfig) *PostValidator {
+	return &PostValidator{
+		maxLength:    cfg.MaxPostLength,
+		maxBodyBytes: int64(cfg.MaxBodyBytes),
+		patterns:     compilePatterns(reflect.TypeOf(model.Post{})),
+	}
+}
+
+// DecodePost reads a post from the request body and returns it with normalized text or *model.ValidationError
+func (v *PostValidator) DecodePost(rw http.ResponseWriter, r *http.Request) (model.Post, error) {
+	var post model.Post
+
+	decoder := json.NewDecoder(http.MaxBytesReader(rw, r.Body, v.maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&post); err != nil {
+		return post, decodingError(err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return post, fieldError("", CodeMalformed, "The body must contain a single JSON object")
+	}
+
+	post.Text = NormalizeText(post.Text)
+
+	var fields []model.FieldError
+
+	switch length := uniseg.GraphemeClusterCount(post.Text); {
+	// the decoder replaces invalid UTF-8 with U+FFFD, so the text is rejected rather than stored with it
+	case strings.ContainsRune(post.Text, utf8.RuneError):
+		fields = append(fields, model.FieldError{
+			Field:   "text",
+			Code:    CodeInvalidEncoding,
+			Message: "The text must be valid UTF-8 without replacement characters",
+		})
+	case strings.TrimSpace(post.Text) == "":
+		fields = append(fields, model.FieldError{Field: "text", Code: CodeRequired, Message: "The text must not be empty"})
+	case length > v.maxLength:
+		fields = append(fields, model.FieldError{
+			Field:   "text",
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("The text must not be longer than %d characters, got %d", v.maxLength, length),
+		})
+	}
+
+	fields = append(fields, v.checkPatterns(post)...)
+
+	if len(fields) > 0 {
+		return post, &model.ValidationError{Fields: fields}
+	}
+
+	return post, nil
+}
+
+// lineBreaks maps CRLF and CR line breaks to LF
+var lineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// NormalizeText maps line breaks to LF, strips other control characters (except tabs) and converts text to
+// Unicode NFC
+func NormalizeText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, lineBreaks.Replace(text))
+
+	return norm.NFC.String(text)
+}
+
+func decodingError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return fieldError("", CodeTooLarge, fmt.Sprintf("The body must not be larger than %d bytes", maxBytesErr.Limit))
+	case errors.As(err, &typeErr):
+		return fieldError(typeErr.Field, CodeInvalidType, fmt.Sprintf("The field must be of type %s", typeErr.Type))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return fieldError(field, CodeUnknownField, "The field is not supported")
+	default:
+		return fieldError("", CodeMalformed, "The body must be a valid JSON object")
+	}
+}
+
+func fieldError(field, code, message string) error {
+	return &model.ValidationError{Fields: []model.FieldError{{Field: field, Code: code, Message: message}}}
+}
+
+// compilePatterns collects `pattern` tags of string fields keyed by their json names
+func compilePatterns(t reflect.Type) map[string]*regexp.Regexp {
+	patterns := make(map[string]*regexp.Regexp)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		pattern, ok := field.Tag.Lookup("pattern")
+		if !ok || field.Type.Kind() != reflect.String {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		patterns[name] = regexp.MustCompile("^(?:" + pattern + ")$")
+	}
+
+	return patterns
+}
+
+// checkPatterns enforces `pattern` tags of model.Post for non-empty fields
+func (v *PostValidator) checkPatterns(post model.Post) []model.FieldError {
+	var fields []model.FieldError
+	value := reflect.ValueOf(post)
+	t := value.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		pattern, ok := v.patterns[name]
+		if !ok {
+			continue
+		}
+
+		s := value.Field(i).String()
+		if s != "" && !pattern.MatchString(s) {
+			fields = append(fields, model.FieldError{
+				Field:   name,
+				Code:    CodePatternMismatch,
+				Message: fmt.Sprintf("The field must match pattern %s", pattern.String()),
+			})
+		}
+	}
+
+	return fields
+}
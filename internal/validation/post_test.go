@@ -0,0 +1,83 @@
+package validation
+
+import (
+	"errors"
+	"microblog/internal/config"
+	"microblog/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodePost(t *testing.T) {
+	v := NewPostValidator(config.ValidationConfig{MaxPostLength: 5, MaxBodyBytes: 1 << 10})
+
+	// x has no precomposed forms, so NFC keeps the sequence a single cluster of three code points
+	combining := "x\u0301\u0302"
+	family := "\U0001F469\u200D\U0001F469\u200D\U0001F467\u200D\U0001F466"
+
+	for _, tc := range []struct {
+		name  string
+		body  string
+		field string
+		code  string
+	}{
+		{name: "Valid", body: `{"text":"hello"}`},
+		{name: "CombiningSequencesAtLimit", body: `{"text":"` + strings.Repeat(combining, 5) + `"}`},
+		{name: "CombiningSequencesOverLimit", body: `{"text":"` + strings.Repeat(combining, 6) + `"}`, field: "text", code: CodeTooLong},
+		{name: "EmojiZWJClustersAtLimit", body: `{"text":"` + strings.Repeat(family, 5) + `"}`},
+		{name: "EmojiZWJClustersOverLimit", body: `{"text":"` + strings.Repeat(family, 6) + `"}`, field: "text", code: CodeTooLong},
+		{name: "Empty", body: `{"text":" \n "}`, field: "text", code: CodeRequired},
+		{name: "UnknownField", body: `{"text":"hello","likes":1}`, field: "likes", code: CodeUnknownField},
+		{name: "InvalidType", body: `{"text":1}`, field: "text", code: CodeInvalidType},
+		{name: "BodyOverLimit", body: `{"text":"` + strings.Repeat("a", 1<<10) + `"}`, code: CodeTooLarge},
+		{name: "Malformed", body: `{"text":`, code: CodeMalformed},
+		{name: "SeveralObjects", body: `{"text":"hello"}{}`, code: CodeMalformed},
+		{name: "PatternMismatch", body: `{"text":"hello","authorId":"XYZ"}`, field: "authorId", code: CodePatternMismatch},
+		{name: "PatternMatch", body: `{"text":"hello","authorId":"0af"}`},
+		{name: "InvalidUTF8", body: "{\"text\":\"a\xffb\"}", field: "text", code: CodeInvalidEncoding},
+		{name: "ReplacementCharacter", body: `{"text":"a\ufffdb"}`, field: "text", code: CodeInvalidEncoding},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(tc.body))
+			_, err := v.DecodePost(httptest.NewRecorder(), r)
+
+			if tc.code == "" {
+				if err != nil {
+					t.Fatalf("got error %+v, want none", err)
+				}
+				return
+			}
+
+			var validationErr *model.ValidationError
+			if !errors.As(err, &validationErr) || !errors.Is(err, model.InvalidRequestBody) {
+				t.Fatalf("got error %v, want a validation error", err)
+			}
+			if len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != tc.field || validationErr.Fields[0].Code != tc.code {
+				t.Fatalf("got %+v, want code %s of field %q", validationErr.Fields, tc.code, tc.field)
+			}
+		})
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "Composed", text: "e\u0301", want: "\u00e9"},
+		{name: "ControlCharacters", text: "a\x00b\x1bc\u0085d", want: "abcd"},
+		{name: "LineBreaksAndTabs", text: "a\nb\tc", want: "a\nb\tc"},
+		{name: "CRLF", text: "a\r\nb\r\n", want: "a\nb\n"},
+		{name: "CR", text: "a\rb", want: "a\nb"},
+		{name: "ReplacementCharacterIsKept", text: "a\ufffdb", want: "a\ufffdb"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NormalizeText(tc.text); got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}